@@ -0,0 +1,190 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// returned by BufferedPort reads after incoming bytes were dropped
+var ErrOverflow = errors.New("ring buffer overflow")
+
+// keeps draining the wrapped port into a ring buffer from a
+// dedicated goroutine so slow consumers do not overflow the
+// driver buffers, bytes arriving with the ring full are dropped
+// and reported once as ErrOverflow along with the data that
+// precedes the loss, the ring is single producer single consumer
+// and needs no locks, the Port concurrency contract is kept
+type BufferedPort struct {
+	port      Port
+	ring      []byte
+	mask      uint64
+	head      uint64 // advanced by the drain goroutine only
+	tail      uint64 // advanced by the reader only
+	overflows uint64 // dropped bytes
+	lost      uint32 // loss not yet reported
+	notify    chan struct{}
+	done      chan struct{}
+	err       error // set before done is closed
+	mu        sync.Mutex
+	timeout   int
+	closed    bool
+}
+
+// size is rounded up to a power of two
+func NewBufferedPort(port Port, size int) *BufferedPort {
+	capacity := 64
+	for capacity < size {
+		capacity <<= 1
+	}
+	bp := &BufferedPort{
+		port:    port,
+		ring:    make([]byte, capacity),
+		mask:    uint64(capacity - 1),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		timeout: -1,
+	}
+	go bp.drain()
+	return bp
+}
+
+func (bp *BufferedPort) drain() {
+	defer close(bp.done)
+	defer bp.wake()
+	bp.err = bp.port.SetReadTimeout(-1)
+	if bp.err != nil {
+		return
+	}
+	size := uint64(len(bp.ring))
+	scratch := make([]byte, 256)
+	for {
+		head := bp.head
+		free := size - (head - atomic.LoadUint64(&bp.tail))
+		if free == 0 {
+			//keep the driver drained, drop what does not fit
+			n, err := bp.port.Read(scratch)
+			if n > 0 {
+				atomic.AddUint64(&bp.overflows, uint64(n))
+				atomic.StoreUint32(&bp.lost, 1)
+				bp.wake()
+			}
+			if err != nil {
+				bp.err = err
+				return
+			}
+			continue
+		}
+		start := head & bp.mask
+		end := start + free
+		if end > size {
+			end = size
+		}
+		n, err := bp.port.Read(bp.ring[start:end])
+		if n > 0 {
+			atomic.StoreUint64(&bp.head, head+uint64(n))
+			bp.wake()
+		}
+		if err != nil {
+			bp.err = err
+			return
+		}
+	}
+}
+
+func (bp *BufferedPort) wake() {
+	select {
+	case bp.notify <- struct{}{}:
+	default:
+	}
+}
+
+// bytes dropped because the ring was full
+func (bp *BufferedPort) Overflows() uint64 {
+	return atomic.LoadUint64(&bp.overflows)
+}
+
+// same semantics as the wrapped port, served from the ring
+func (bp *BufferedPort) SetReadTimeout(toms int) (err error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if bp.closed {
+		return io.EOF
+	}
+	bp.timeout = toms
+	return
+}
+
+// buffered data is delivered before the error that stopped draining
+func (bp *BufferedPort) Read(p []byte) (n int, err error) {
+	bp.mu.Lock()
+	toms := bp.timeout
+	bp.mu.Unlock()
+	var timer <-chan time.Time
+	if toms > 0 {
+		t := time.NewTimer(time.Duration(toms) * time.Millisecond)
+		defer t.Stop()
+		timer = t.C
+	}
+	for {
+		n = bp.take(p)
+		if n > 0 || len(p) == 0 {
+			if atomic.SwapUint32(&bp.lost, 0) == 1 {
+				err = ErrOverflow
+			}
+			return
+		}
+		select {
+		case <-bp.done:
+			//drain stored the last data before closing done
+			n = bp.take(p)
+			if n == 0 {
+				err = bp.err
+			}
+			return
+		default:
+		}
+		if toms == 0 {
+			return
+		}
+		select {
+		case <-bp.notify:
+		case <-bp.done:
+		case <-timer:
+			return
+		}
+	}
+}
+
+func (bp *BufferedPort) take(p []byte) (n int) {
+	tail := bp.tail
+	avail := atomic.LoadUint64(&bp.head) - tail
+	for n < len(p) && avail > 0 {
+		start := tail & bp.mask
+		end := start + avail
+		if end > uint64(len(bp.ring)) {
+			end = uint64(len(bp.ring))
+		}
+		c := copy(p[n:], bp.ring[start:end])
+		n += c
+		tail += uint64(c)
+		avail -= uint64(c)
+	}
+	atomic.StoreUint64(&bp.tail, tail)
+	return
+}
+
+func (bp *BufferedPort) Write(p []byte) (n int, err error) {
+	return bp.port.Write(p)
+}
+
+// closes the wrapped port which stops the drain goroutine
+func (bp *BufferedPort) Close() (err error) {
+	bp.mu.Lock()
+	bp.closed = true
+	bp.mu.Unlock()
+	err = bp.port.Close()
+	return
+}
@@ -0,0 +1,75 @@
+//go:build linux
+
+package serial
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBufferedPort(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	bp := NewBufferedPort(port, 1<<16)
+	fatalIfError(t, bp.SetReadTimeout(20))
+	buf := make([]byte, 64)
+	n, err := bp.Read(buf)
+	if n != 0 || err != nil {
+		t.Fatalf("unexpected read %d %v", n, err)
+	}
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	go master.Write(payload)
+	fatalIfError(t, bp.SetReadTimeout(1000))
+	got := make([]byte, 0, len(payload))
+	for len(got) < len(payload) {
+		n, err = bp.Read(buf)
+		fatalIfError(t, err)
+		if n == 0 {
+			t.Fatalf("timeout after %d bytes", len(got))
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, payload) || bp.Overflows() != 0 {
+		t.Fatalf("unexpected data %d bytes, %d overflows", len(got), bp.Overflows())
+	}
+	fatalIfError(t, bp.Close())
+	_, err = bp.Read(buf)
+	if err != io.EOF {
+		t.Fatalf("close not detected %v", err)
+	}
+	if bp.SetReadTimeout(0) != io.EOF {
+		t.Fatal("close not detected by SetReadTimeout")
+	}
+}
+
+func TestBufferedPortOverflow(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	bp := NewBufferedPort(port, 100)
+	defer bp.Close()
+	payload := bytes.Repeat([]byte{'x'}, 4096)
+	_, err := master.Write(payload)
+	fatalIfError(t, err)
+	//consumer stalls, the drain goroutine keeps going
+	deadline := time.Now().Add(time.Second)
+	for bp.Overflows() != uint64(len(payload)-128) {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected overflows %d", bp.Overflows())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	fatalIfError(t, bp.SetReadTimeout(0))
+	buf := make([]byte, 256)
+	n, err := bp.Read(buf)
+	if n != 128 || err != ErrOverflow {
+		t.Fatalf("overflow not reported %d %v", n, err)
+	}
+	n, err = bp.Read(buf)
+	if n != 0 || err != nil {
+		t.Fatalf("unexpected read %d %v", n, err)
+	}
+}
@@ -0,0 +1,73 @@
+package serial
+
+import "time"
+
+// implemented by Device
+type TimestampedReader interface {
+	ReadTimestamped(p []byte) (n int, at time.Time, err error)
+}
+
+// bytes received without a gap in between
+type Chunk struct {
+	Data  []byte
+	Start time.Time // arrival of the first read
+	End   time.Time // arrival of the last read
+}
+
+// splits input into chunks on inter byte gaps
+// arrival times are those of the reads, so bytes arriving
+// together in one read share a timestamp
+// owns the read side and its timeout while reading
+type ChunkReader struct {
+	port Port
+	gap  time.Duration
+	buf  []byte
+}
+
+// gap is rounded up to the read timeout resolution of 1ms
+func NewChunkReader(port Port, gap time.Duration) *ChunkReader {
+	return &ChunkReader{port: port, gap: gap, buf: make([]byte, 256)}
+}
+
+// waits up to timeout for a chunk to start, negative waits forever
+// ErrTimeout if none started, the chunk ends once gap elapses
+// with no new data, a chunk cut by an error is returned with it
+func (cr *ChunkReader) ReadChunk(timeout time.Duration) (chunk *Chunk, err error) {
+	err = cr.port.SetReadTimeout(durationToMs(timeout))
+	if err != nil {
+		return
+	}
+	n, at, err := cr.read()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		err = ErrTimeout
+		return
+	}
+	chunk = &Chunk{Start: at, End: at}
+	chunk.Data = append(chunk.Data, cr.buf[:n]...)
+	err = cr.port.SetReadTimeout(durationToMs(cr.gap))
+	if err != nil {
+		return
+	}
+	for {
+		n, at, err = cr.read()
+		if n > 0 {
+			chunk.Data = append(chunk.Data, cr.buf[:n]...)
+			chunk.End = at
+		}
+		if n == 0 || err != nil {
+			return
+		}
+	}
+}
+
+func (cr *ChunkReader) read() (n int, at time.Time, err error) {
+	if tr, ok := cr.port.(TimestampedReader); ok {
+		return tr.ReadTimestamped(cr.buf)
+	}
+	n, err = cr.port.Read(cr.buf)
+	at = time.Now()
+	return
+}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package serial
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChunkReader(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	go func() {
+		master.Write([]byte("abc"))
+		time.Sleep(5 * time.Millisecond)
+		master.Write([]byte("def"))
+		time.Sleep(100 * time.Millisecond)
+		master.Write([]byte("ghi"))
+	}()
+	cr := NewChunkReader(port, 40*time.Millisecond)
+	chunk, err := cr.ReadChunk(time.Second)
+	fatalIfError(t, err)
+	if string(chunk.Data) != "abcdef" || chunk.End.Before(chunk.Start) {
+		t.Fatalf("unexpected chunk %q %v %v", chunk.Data, chunk.Start, chunk.End)
+	}
+	first := chunk
+	chunk, err = cr.ReadChunk(time.Second)
+	fatalIfError(t, err)
+	if string(chunk.Data) != "ghi" {
+		t.Fatalf("unexpected chunk %q", chunk.Data)
+	}
+	gap := chunk.Start.Sub(first.End)
+	if gap < 80*time.Millisecond {
+		t.Fatalf("unexpected gap %v", gap)
+	}
+	_, err = cr.ReadChunk(10 * time.Millisecond)
+	if err != ErrTimeout {
+		t.Fatalf("timeout not detected %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/samuelventura/go-serial"
+)
+
+// (cd cmd/serial; go run . doctor /dev/ttyUSB0)
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "doctor" {
+		fmt.Fprintln(os.Stderr, "usage: serial doctor <port>...")
+		os.Exit(2)
+	}
+	code := 0
+	for i, name := range os.Args[2:] {
+		if i > 0 {
+			fmt.Println()
+		}
+		report := serial.Diagnose(name)
+		fmt.Print(report)
+		if len(report.Problems) > 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
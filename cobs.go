@@ -0,0 +1,253 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// frame that is not valid COBS or too short for its checksum
+var ErrCobsDecode = errors.New("invalid cobs frame")
+
+// frame that decoded fine but failed its checksum
+var ErrChecksum = errors.New("checksum mismatch")
+
+// trailer appended to packets before COBS encoding,
+// checksums are computed over the payload and appended
+// little endian
+type Checksum int
+
+const (
+	NoChecksum Checksum = iota
+	CRC16               // CRC-16/CCITT-FALSE, poly 0x1021 init 0xFFFF
+	CRC32               // CRC-32/IEEE as in hash/crc32
+)
+
+// used when the packet size limit is not positive
+const cobsDefaultMax = 256
+
+var crc16Table = makeCrc16Table()
+
+func makeCrc16Table() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return
+}
+
+func crc16(p []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range p {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+func (sum Checksum) size() int {
+	switch sum {
+	case CRC16:
+		return 2
+	case CRC32:
+		return 4
+	}
+	return 0
+}
+
+// appends the checksum of p to dst
+func (sum Checksum) append(dst, p []byte) []byte {
+	switch sum {
+	case CRC16:
+		crc := crc16(p)
+		return append(dst, byte(crc), byte(crc>>8))
+	case CRC32:
+		crc := crc32.ChecksumIEEE(p)
+		return append(dst, byte(crc), byte(crc>>8), byte(crc>>16), byte(crc>>24))
+	}
+	return dst
+}
+
+// longest encoding of n bytes, delimiter not included
+func CobsMaxEncodedLen(n int) int {
+	return n + n/254 + 1
+}
+
+// encodes src into dst which must hold CobsMaxEncodedLen
+// bytes, the zero delimiter is not written
+func CobsEncode(dst, src []byte) (n int) {
+	at := 0
+	n = 1
+	code := byte(1)
+	for i, b := range src {
+		if b == 0 {
+			dst[at] = code
+			at, n, code = n, n+1, 1
+			continue
+		}
+		dst[n] = b
+		n++
+		code++
+		//a full block at the very end needs no trailing code
+		if code == 0xFF && i < len(src)-1 {
+			dst[at] = code
+			at, n, code = n, n+1, 1
+		}
+	}
+	dst[at] = code
+	return
+}
+
+// decodes src, without its delimiter, into dst which needs
+// no more than len(src) bytes, io.ErrShortBuffer if too small
+func CobsDecode(dst, src []byte) (n int, err error) {
+	for i := 0; i < len(src); {
+		code := int(src[i])
+		i++
+		end := i + code - 1
+		if code == 0 || end > len(src) {
+			return n, ErrCobsDecode
+		}
+		if n+end-i > len(dst) {
+			return n, io.ErrShortBuffer
+		}
+		n += copy(dst[n:], src[i:end])
+		i = end
+		if code < 0xFF && i < len(src) {
+			if n >= len(dst) {
+				return n, io.ErrShortBuffer
+			}
+			dst[n] = 0
+			n++
+		}
+	}
+	return
+}
+
+// writes zero delimited COBS packets, each in a single Write,
+// buffers are sized at construction so writing never allocates
+type CobsWriter struct {
+	w     io.Writer
+	sum   Checksum
+	max   int
+	plain []byte
+	enc   []byte
+}
+
+// max limits the payload, checksum not included
+func NewCobsWriter(w io.Writer, sum Checksum, max int) *CobsWriter {
+	if max <= 0 {
+		max = cobsDefaultMax
+	}
+	size := max + sum.size()
+	return &CobsWriter{
+		w:     w,
+		sum:   sum,
+		max:   max,
+		plain: make([]byte, 0, size),
+		enc:   make([]byte, CobsMaxEncodedLen(size)+1),
+	}
+}
+
+// ErrFrameTooLong if packet exceeds max, nothing is written then
+func (cw *CobsWriter) WritePacket(packet []byte) (err error) {
+	if len(packet) > cw.max {
+		return ErrFrameTooLong
+	}
+	plain := cw.sum.append(append(cw.plain[:0], packet...), packet)
+	n := CobsEncode(cw.enc, plain)
+	cw.enc[n] = 0
+	_, err = cw.w.Write(cw.enc[:n+1])
+	return
+}
+
+// reads zero delimited COBS packets, frames that fail are
+// dropped and reported, empty frames are skipped, buffers are
+// sized at construction so reading never allocates
+type CobsReader struct {
+	in      packetSource
+	sum     Checksum
+	frame   []byte
+	plain   []byte
+	toolong bool
+}
+
+// max limits the payload, checksum not included
+func NewCobsReader(r io.Reader, sum Checksum, max int) *CobsReader {
+	if max <= 0 {
+		max = cobsDefaultMax
+	}
+	size := max + sum.size()
+	return &CobsReader{
+		in:    newPacketSource(r),
+		sum:   sum,
+		frame: make([]byte, 0, CobsMaxEncodedLen(size)),
+		plain: make([]byte, size),
+	}
+}
+
+// drops the frame being received
+func (cr *CobsReader) Reset() {
+	cr.frame = cr.frame[:0]
+	cr.toolong = false
+}
+
+// waits up to timeout overall for a packet, negative waits
+// forever, the timeout only applies when reading from a Port,
+// the packet is valid until the next call, on ErrTimeout the
+// partial frame is kept for the next call, ErrCobsDecode,
+// ErrChecksum and ErrFrameTooLong drop the frame, read errors
+// are returned as they come
+func (cr *CobsReader) ReadPacket(timeout time.Duration) (packet []byte, err error) {
+	err = cr.in.next(timeout, func(chunk []byte) (n int, done bool, err error) {
+		for n < len(chunk) {
+			b := chunk[n]
+			n++
+			if b != 0 {
+				if len(cr.frame) == cap(cr.frame) {
+					cr.toolong = true
+				} else {
+					cr.frame = append(cr.frame, b)
+				}
+				continue
+			}
+			if cr.toolong {
+				cr.Reset()
+				return n, true, ErrFrameTooLong
+			}
+			if len(cr.frame) == 0 {
+				continue
+			}
+			packet, err = cr.decode()
+			cr.Reset()
+			return n, true, err
+		}
+		return
+	})
+	return
+}
+
+func (cr *CobsReader) decode() (packet []byte, err error) {
+	n, err := CobsDecode(cr.plain, cr.frame)
+	if err == io.ErrShortBuffer {
+		return nil, ErrFrameTooLong
+	}
+	size := cr.sum.size()
+	if err != nil || n < size {
+		return nil, ErrCobsDecode
+	}
+	packet = cr.plain[:n-size]
+	var trailer [4]byte
+	if !bytes.Equal(cr.sum.append(trailer[:0], packet), cr.plain[n-size:n]) {
+		return nil, ErrChecksum
+	}
+	return
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package serial
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCobsPort(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := master.Read(buf)
+		master.Write(buf[:n/2])
+		time.Sleep(50 * time.Millisecond)
+		master.Write(buf[n/2 : n])
+	}()
+	cw := NewCobsWriter(port, CRC16, 32)
+	cr := NewCobsReader(port, CRC16, 32)
+	fatalIfError(t, cw.WritePacket([]byte{0x00, 0x7E, 0x00}))
+	_, err := cr.ReadPacket(20 * time.Millisecond)
+	if err != ErrTimeout {
+		t.Fatalf("timeout not detected %v", err)
+	}
+	//partial frame survives the timeout
+	packet, err := cr.ReadPacket(time.Second)
+	fatalIfError(t, err)
+	if string(packet) != "\x00\x7e\x00" {
+		t.Fatalf("unexpected packet % x", packet)
+	}
+}
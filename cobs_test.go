@@ -0,0 +1,136 @@
+package serial
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func cobsSequence(from, to int) (seq []byte) {
+	for i := from; i <= to; i++ {
+		seq = append(seq, byte(i))
+	}
+	return
+}
+
+func TestCobsVectors(t *testing.T) {
+	for _, tc := range []struct {
+		plain   []byte
+		encoded []byte
+	}{
+		{[]byte{}, []byte{0x01}},
+		{[]byte{0x00}, []byte{0x01, 0x01}},
+		{[]byte{0x00, 0x00}, []byte{0x01, 0x01, 0x01}},
+		{[]byte{0x11, 0x22, 0x00, 0x33}, []byte{0x03, 0x11, 0x22, 0x02, 0x33}},
+		{[]byte{0x11, 0x22, 0x33, 0x44}, []byte{0x05, 0x11, 0x22, 0x33, 0x44}},
+		{[]byte{0x11, 0x00, 0x00, 0x00}, []byte{0x02, 0x11, 0x01, 0x01, 0x01}},
+		{cobsSequence(0x01, 0xFE), append([]byte{0xFF}, cobsSequence(0x01, 0xFE)...)},
+		{cobsSequence(0x00, 0xFE), append([]byte{0x01, 0xFF}, cobsSequence(0x01, 0xFE)...)},
+		{cobsSequence(0x01, 0xFF), append(append([]byte{0xFF}, cobsSequence(0x01, 0xFE)...), 0x02, 0xFF)},
+	} {
+		enc := make([]byte, CobsMaxEncodedLen(len(tc.plain)))
+		n := CobsEncode(enc, tc.plain)
+		if !bytes.Equal(enc[:n], tc.encoded) {
+			t.Fatalf("unexpected encoding % x", enc[:n])
+		}
+		dec := make([]byte, len(tc.plain))
+		n, err := CobsDecode(dec, tc.encoded)
+		fatalIfError(t, err)
+		if !bytes.Equal(dec[:n], tc.plain) {
+			t.Fatalf("unexpected decoding % x", dec[:n])
+		}
+	}
+	_, err := CobsDecode(make([]byte, 8), []byte{0x05, 0x11})
+	if err != ErrCobsDecode {
+		t.Fatalf("invalid frame not detected %v", err)
+	}
+	_, err = CobsDecode(make([]byte, 1), []byte{0x03, 0x11, 0x22})
+	if err != io.ErrShortBuffer {
+		t.Fatalf("short buffer not detected %v", err)
+	}
+}
+
+func TestCobsChecksums(t *testing.T) {
+	check := []byte("123456789")
+	if crc := crc16(check); crc != 0x29B1 {
+		t.Fatalf("unexpected crc16 %04x", crc)
+	}
+	if got := CRC32.append(nil, check); !bytes.Equal(got, []byte{0x26, 0x39, 0xF4, 0xCB}) {
+		t.Fatalf("unexpected crc32 % x", got)
+	}
+}
+
+type cobsExpect struct {
+	packet []byte
+	err    error
+}
+
+func TestCobsReader(t *testing.T) {
+	for _, sum := range []Checksum{NoChecksum, CRC16, CRC32} {
+		var wire bytes.Buffer
+		cw := NewCobsWriter(&wire, sum, 16)
+		fatalIfError(t, cw.WritePacket([]byte{0x01, 0x00, 0x02}))
+		if cw.WritePacket(make([]byte, 17)) != ErrFrameTooLong {
+			t.Fatal("oversized packet not rejected")
+		}
+		fatalIfError(t, cw.WritePacket([]byte{0xAA, 0x00, 0x00, 0x00, 0xBB}))
+		if sum != NoChecksum {
+			//flip a payload bit keeping the encoding valid
+			fatalIfError(t, cw.WritePacket([]byte{0x10, 0x20}))
+			raw := wire.Bytes()
+			raw[len(raw)-2-sum.size()] ^= 0x01
+		}
+		wire.Write([]byte{0x00, 0x05, 0x11, 0x00})
+		wire.Write(bytes.Repeat([]byte{0x42}, 40))
+		wire.WriteByte(0x00)
+		fatalIfError(t, cw.WritePacket(nil))
+		fatalIfError(t, cw.WritePacket([]byte{0x33}))
+		cr := NewCobsReader(&wire, sum, 16)
+		expect := []cobsExpect{
+			{[]byte{0x01, 0x00, 0x02}, nil},
+			{[]byte{0xAA, 0x00, 0x00, 0x00, 0xBB}, nil},
+		}
+		if sum != NoChecksum {
+			expect = append(expect, cobsExpect{nil, ErrChecksum})
+		}
+		expect = append(expect,
+			cobsExpect{nil, ErrCobsDecode},
+			cobsExpect{nil, ErrFrameTooLong},
+			cobsExpect{[]byte{}, nil},
+			cobsExpect{[]byte{0x33}, nil},
+			cobsExpect{nil, io.EOF})
+		for _, e := range expect {
+			got, err := cr.ReadPacket(-1)
+			if err != e.err || !bytes.Equal(got, e.packet) {
+				t.Fatalf("checksum %d unexpected % x %v expected % x %v", sum, got, err, e.packet, e.err)
+			}
+		}
+	}
+}
+
+func TestCobsAllocs(t *testing.T) {
+	packet := []byte{0x01, 0x00, 0x02, 0x00, 0x03, 0x04}
+	var wire bytes.Buffer
+	NewCobsWriter(&wire, CRC32, 64).WritePacket(packet)
+	raw := wire.Bytes()
+	cw := NewCobsWriter(ioutil.Discard, CRC32, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		cw.WritePacket(packet)
+	})
+	if allocs != 0 {
+		t.Fatalf("write allocates %v", allocs)
+	}
+	reader := bytes.NewReader(raw)
+	cr := NewCobsReader(reader, CRC32, 64)
+	allocs = testing.AllocsPerRun(100, func() {
+		reader.Reset(raw)
+		got, err := cr.ReadPacket(-1)
+		if err != nil || len(got) != len(packet) {
+			t.Fatal(got, err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("read allocates %v", allocs)
+	}
+}
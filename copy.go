@@ -0,0 +1,76 @@
+package serial
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// buffers for the ReadFrom and WriteTo loops, a few tty
+// flip buffers worth so each syscall moves plenty of data
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 16<<10)
+		return &buf
+	},
+}
+
+// writes r to port until r returns EOF
+func copyToPort(port Port, r io.Reader) (n int64, err error) {
+	bufp := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(bufp)
+	buf := *bufp
+	for {
+		nr, rerr := r.Read(buf)
+		if nr > 0 {
+			var nw int
+			nw, err = port.Write(buf[:nr])
+			n += int64(nw)
+			if err != nil {
+				return
+			}
+		}
+		if rerr == io.EOF {
+			return
+		}
+		if rerr != nil {
+			err = rerr
+			return
+		}
+	}
+}
+
+// pause after empty reads so zero read timeouts do not spin
+const copyIdle = 10 * time.Millisecond
+
+// reads into w until read reports the port closed, which is not
+// an error, or w fails, timeouts are read through
+func copyFromPort(w io.Writer, read func(p []byte) (int, error)) (n int64, err error) {
+	bufp := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(bufp)
+	buf := *bufp
+	for {
+		nr, rerr := read(buf)
+		if nr > 0 {
+			var nw int
+			nw, err = w.Write(buf[:nr])
+			n += int64(nw)
+			if err == nil && nw < nr {
+				err = io.ErrShortWrite
+			}
+			if err != nil {
+				return
+			}
+		}
+		if rerr == io.EOF {
+			return
+		}
+		if rerr != nil {
+			err = rerr
+			return
+		}
+		if nr == 0 {
+			time.Sleep(copyIdle)
+		}
+	}
+}
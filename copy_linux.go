@@ -0,0 +1,213 @@
+//go:build linux
+
+package serial
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const spliceChunk = 1 << 20
+
+// kernel copies, vars so tests can tell which path ran
+var (
+	sysSendfile = unix.Sendfile
+	sysSplice   = unix.Splice
+)
+
+// copies regular files to the port with sendfile, handled is
+// false when r is not a regular file or the kernel refuses
+// the tty as destination, nothing was copied in that case
+func (port *Device) sendFrom(r io.Reader) (n int64, handled bool, err error) {
+	remain := int64(-1)
+	lr, limited := r.(*io.LimitedReader)
+	if limited {
+		remain = lr.N
+		r = lr.R
+	}
+	src, ok := regularFile(r)
+	if !ok {
+		return
+	}
+	if !port.enter() {
+		return 0, true, io.EOF
+	}
+	defer port.io.RUnlock()
+	_, toms := port.timeouts()
+	handled = true
+	cerr := src.Read(func(fd uintptr) bool {
+		deadline := deadlineFor(toms)
+		for remain != 0 {
+			chunk := int64(spliceChunk)
+			if remain > 0 && remain < chunk {
+				chunk = remain
+			}
+			var c int
+			c, err = sysSendfile(port.handle, int(fd), nil, int(chunk))
+			if err == unix.EAGAIN || err == unix.EINTR {
+				var ready bool
+				ready, err = port.wait(unix.POLLOUT, deadline)
+				if err == nil && !ready {
+					err = ErrTimeout
+				}
+				if err != nil {
+					return true
+				}
+				continue
+			}
+			if (err == unix.EINVAL || err == unix.ENOSYS) && n == 0 {
+				handled, err = false, nil
+				return true
+			}
+			err = tryConvertToEof(err)
+			if err != nil || c <= 0 {
+				return true
+			}
+			n += int64(c)
+			if remain > 0 {
+				remain -= int64(c)
+			}
+			deadline = deadlineFor(toms)
+		}
+		return true
+	})
+	if err == nil {
+		err = cerr
+	}
+	if limited {
+		lr.N -= n
+	}
+	return
+}
+
+func regularFile(r io.Reader) (conn syscall.RawConn, ok bool) {
+	//also matches the wrapper os.File.WriteTo hands to ReadFrom
+	file, ok := r.(interface {
+		syscall.Conn
+		Stat() (os.FileInfo, error)
+	})
+	if !ok {
+		return
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, false
+	}
+	conn, err = file.SyscallConn()
+	return conn, err == nil
+}
+
+// moves port data to w through a pipe with splice, handled is
+// false when w has no descriptor or either end refuses splice,
+// n bytes were already copied in that case, waits for data in
+// poll regardless of the read timeout
+func (port *Device) spliceTo(w io.Writer) (n int64, handled bool, err error) {
+	if port.marking != nil {
+		return
+	}
+	sc, ok := w.(syscall.Conn)
+	if !ok {
+		return
+	}
+	dst, err := sc.SyscallConn()
+	if err != nil {
+		return 0, false, nil
+	}
+	pipe := make([]int, 2)
+	err = unix.Pipe2(pipe, unix.O_CLOEXEC|unix.O_NONBLOCK)
+	if err != nil {
+		return 0, false, nil
+	}
+	defer unix.Close(pipe[0])
+	defer unix.Close(pipe[1])
+	if !port.enter() {
+		return 0, true, nil
+	}
+	defer port.io.RUnlock()
+	handled = true
+	//built once, a closure per chunk would allocate
+	var c int64
+	refused := false
+	drain := func(fd uintptr) bool {
+		for c > 0 {
+			m, serr := sysSplice(pipe[0], nil, int(fd), nil, int(c), unix.SPLICE_F_NONBLOCK|unix.SPLICE_F_MOVE)
+			switch {
+			case serr == unix.EAGAIN:
+				return false
+			case serr == unix.EINTR:
+				continue
+			case serr == unix.EINVAL:
+				refused = true
+				return true
+			case serr != nil:
+				err = serr
+				return true
+			}
+			c -= m
+			n += m
+		}
+		return true
+	}
+	for {
+		var ready bool
+		ready, err = port.wait(unix.POLLIN, time.Time{})
+		if err == io.EOF {
+			err = nil
+		}
+		if err != nil || !ready {
+			return
+		}
+		c, err = sysSplice(port.handle, nil, pipe[1], nil, spliceChunk, unix.SPLICE_F_NONBLOCK)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+		if err == unix.EINVAL && n == 0 {
+			return 0, false, nil
+		}
+		err = tryConvertToEof(err)
+		if err == io.EOF {
+			err = nil
+		}
+		//readable with nothing to read is a hangup
+		if err != nil || c <= 0 {
+			return
+		}
+		werr := dst.Write(drain)
+		if err == nil {
+			err = werr
+		}
+		if err != nil {
+			return
+		}
+		if refused {
+			//hand what is left in the pipe over the slow path
+			var m int64
+			m, err = drainPipe(w, pipe[0], c)
+			n += m
+			return n, err != nil, err
+		}
+	}
+}
+
+func drainPipe(w io.Writer, fd int, c int64) (n int64, err error) {
+	bufp := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(bufp)
+	buf := *bufp
+	for n < c {
+		var m int
+		m, err = unix.Read(fd, buf)
+		if err != nil || m <= 0 {
+			return
+		}
+		m, err = w.Write(buf[:m])
+		n += int64(m)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,282 @@
+//go:build linux
+
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func copyPayload(size int) []byte {
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	return payload
+}
+
+var errCopyDone = errors.New("copy done")
+
+// fails once limit bytes were written to stop WriteTo
+type limitWriter struct {
+	w     io.Writer
+	limit int64
+}
+
+func (lw *limitWriter) Write(p []byte) (n int, err error) {
+	n, err = lw.w.Write(p)
+	lw.limit -= int64(n)
+	if err == nil && lw.limit <= 0 {
+		err = errCopyDone
+	}
+	return
+}
+
+type kernelCounts struct {
+	sendfile, splice, poll, read, write int64
+}
+
+func countKernelCopies(counts *kernelCounts) (restore func()) {
+	sendfile, splice, poll, read, write := sysSendfile, sysSplice, sysPoll, sysRead, sysWrite
+	sysSendfile = func(out, in int, offset *int64, count int) (int, error) {
+		atomic.AddInt64(&counts.sendfile, 1)
+		return sendfile(out, in, offset, count)
+	}
+	sysSplice = func(rfd int, roff *int64, wfd int, woff *int64, len int, flags int) (int64, error) {
+		atomic.AddInt64(&counts.splice, 1)
+		return splice(rfd, roff, wfd, woff, len, flags)
+	}
+	sysPoll = func(fds []unix.PollFd, timeout int) (int, error) {
+		atomic.AddInt64(&counts.poll, 1)
+		return poll(fds, timeout)
+	}
+	sysRead = func(fd int, p []byte) (int, error) {
+		atomic.AddInt64(&counts.read, 1)
+		return read(fd, p)
+	}
+	sysWrite = func(fd int, p []byte) (int, error) {
+		atomic.AddInt64(&counts.write, 1)
+		return write(fd, p)
+	}
+	return func() {
+		sysSendfile, sysSplice, sysPoll, sysRead, sysWrite = sendfile, splice, poll, read, write
+	}
+}
+
+func TestSerialReadFrom(t *testing.T) {
+	defer logPanic()
+	payload := copyPayload(256 << 10)
+	path := filepath.Join(t.TempDir(), "image")
+	fatalIfError(t, ioutil.WriteFile(path, payload, 0644))
+	for _, tc := range []struct {
+		name     string
+		reader   func(file *os.File) io.Reader
+		size     int
+		sendfile bool
+	}{
+		{"sendfile", func(file *os.File) io.Reader { return file }, len(payload), true},
+		{"limited", func(file *os.File) io.Reader { return io.LimitReader(file, 1000) }, 1000, true},
+		{"loop", func(file *os.File) io.Reader { return struct{ io.Reader }{file} }, len(payload), false},
+	} {
+		master, port := openPty(t)
+		file, err := os.Open(path)
+		fatalIfError(t, err)
+		got := make(chan []byte)
+		go func() {
+			buf := make([]byte, tc.size)
+			n, _ := io.ReadFull(master, buf)
+			got <- buf[:n]
+		}()
+		counts := &kernelCounts{}
+		restore := countKernelCopies(counts)
+		n, err := port.ReadFrom(tc.reader(file))
+		restore()
+		fatalIfError(t, err)
+		if n != int64(tc.size) || !bytes.Equal(<-got, payload[:tc.size]) {
+			t.Fatalf("%s unexpected copy %d", tc.name, n)
+		}
+		//a refused sendfile falls back to writes
+		if (counts.sendfile > 0 && counts.write == 0) != tc.sendfile {
+			t.Fatalf("%s unexpected path %d sendfile %d write", tc.name, counts.sendfile, counts.write)
+		}
+		file.Close()
+		port.Close()
+		master.Close()
+	}
+}
+
+func TestSerialWriteTo(t *testing.T) {
+	defer logPanic()
+	payload := copyPayload(256 << 10)
+	for _, tc := range []struct {
+		name   string
+		splice bool
+	}{
+		{"splice", true},
+		{"loop", false},
+	} {
+		master, port := openPty(t)
+		fatalIfError(t, port.SetReadTimeout(10))
+		//the pause spans several read timeouts
+		go func() {
+			master.Write(payload[:1000])
+			time.Sleep(50 * time.Millisecond)
+			master.Write(payload[1000:])
+		}()
+		r, w, err := os.Pipe()
+		fatalIfError(t, err)
+		//close ends the copy without error
+		got := make(chan []byte)
+		go func() {
+			buf := make([]byte, len(payload))
+			n, _ := io.ReadFull(r, buf)
+			port.Close()
+			got <- buf[:n]
+		}()
+		var dst io.Writer = w
+		if !tc.splice {
+			dst = struct{ io.Writer }{w}
+		}
+		counts := &kernelCounts{}
+		restore := countKernelCopies(counts)
+		n, err := port.WriteTo(dst)
+		restore()
+		fatalIfError(t, err)
+		if n != int64(len(payload)) || !bytes.Equal(<-got, payload) {
+			t.Fatalf("%s unexpected copy %d", tc.name, n)
+		}
+		if (counts.splice > 0 && counts.read == 0) != tc.splice {
+			t.Fatalf("%s unexpected path %d splice %d read", tc.name, counts.splice, counts.read)
+		}
+		r.Close()
+		w.Close()
+		master.Close()
+	}
+}
+
+// the poller leaves ports with a zero read timeout
+func TestSerialWriteToIdle(t *testing.T) {
+	defer logPanic()
+	for _, name := range []string{"splice", "loop"} {
+		master, port := openPty(t)
+		fatalIfError(t, port.SetReadTimeout(0))
+		r, w, err := os.Pipe()
+		fatalIfError(t, err)
+		var dst io.Writer = w
+		if name == "loop" {
+			dst = struct{ io.Writer }{w}
+		}
+		counts := &kernelCounts{}
+		restore := countKernelCopies(counts)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			port.Close()
+		}()
+		_, err = port.WriteTo(dst)
+		restore()
+		fatalIfError(t, err)
+		if counts.poll > 5 || counts.read > 5 {
+			t.Fatalf("%s spinning %d polls %d reads", name, counts.poll, counts.read)
+		}
+		r.Close()
+		w.Close()
+		master.Close()
+	}
+}
+
+// loop is the pooled buffer behind ReadFrom, io.Copy hides
+// it and allocates its own 32 KiB buffer per copy
+func BenchmarkSerialReadFrom(b *testing.B) {
+	payload := copyPayload(64 << 10)
+	path := filepath.Join(b.TempDir(), "image")
+	if err := ioutil.WriteFile(path, payload, 0644); err != nil {
+		b.Fatal(err)
+	}
+	for _, bc := range []struct {
+		name string
+		copy func(port *Device, file *os.File) (int64, error)
+	}{
+		{"sendfile", func(port *Device, file *os.File) (int64, error) {
+			return port.ReadFrom(file)
+		}},
+		{"loop", func(port *Device, file *os.File) (int64, error) {
+			return port.ReadFrom(struct{ io.Reader }{file})
+		}},
+		{"io.Copy", func(port *Device, file *os.File) (int64, error) {
+			return io.Copy(struct{ io.Writer }{port}, struct{ io.Reader }{file})
+		}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			master, port := openPty(b)
+			defer master.Close()
+			defer port.Close()
+			go io.Copy(ioutil.Discard, master)
+			file, err := os.Open(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer file.Close()
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				file.Seek(0, io.SeekStart)
+				if _, err := bc.copy(port, file); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// each op ends with the port closing once the payload went through
+func BenchmarkSerialWriteTo(b *testing.B) {
+	payload := copyPayload(1 << 20)
+	for _, bc := range []struct {
+		name string
+		copy func(port *Device, w *os.File) (int64, error)
+	}{
+		{"splice", func(port *Device, w *os.File) (int64, error) {
+			return port.WriteTo(w)
+		}},
+		{"loop", func(port *Device, w *os.File) (int64, error) {
+			return port.WriteTo(struct{ io.Writer }{w})
+		}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				master, port := openPty(b)
+				r, w, err := os.Pipe()
+				if err != nil {
+					b.Fatal(err)
+				}
+				go master.Write(payload)
+				go func() {
+					io.CopyN(ioutil.Discard, r, int64(len(payload)))
+					port.Close()
+				}()
+				b.StartTimer()
+				if _, err := bc.copy(port, w); err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				r.Close()
+				w.Close()
+				master.Close()
+				b.StartTimer()
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build darwin || freebsd || openbsd
+
+package serial
+
+import "io"
+
+func (port *Device) sendFrom(r io.Reader) (n int64, handled bool, err error) {
+	return
+}
+
+func (port *Device) spliceTo(w io.Writer) (n int64, handled bool, err error) {
+	return
+}
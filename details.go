@@ -0,0 +1,122 @@
+package serial
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// USB fields are empty for ports not backed by a USB device
+// or on platforms where they cannot be resolved
+type PortDetails struct {
+	Name         string
+	IsUSB        bool
+	VID          string // 4 hex digits lowercase
+	PID          string // 4 hex digits lowercase
+	SerialNumber string
+	Manufacturer string
+	Product      string
+	Location     string // USB interface path like 1-1.2:1.0
+	ByID         string // /dev/serial/by-id link if any
+}
+
+// empty fields match any port
+// VID and PID are hex and case insensitive
+// Product is a case insensitive substring
+// Location matches the interface path or its device prefix
+// ByID is a glob matched against the link name or its full path
+type PortMatch struct {
+	VID          string
+	PID          string
+	SerialNumber string
+	Product      string
+	Location     string
+	ByID         string
+}
+
+// opens the single port matching all set fields
+// fails when zero or multiple ports match
+func OpenMatching(match PortMatch, mode *Mode) (port *Device, err error) {
+	list, err := GetDetailedPortsList()
+	if err != nil {
+		return
+	}
+	name, err := selectPort(match, list)
+	if err != nil {
+		return
+	}
+	port, err = Open(name, mode)
+	return
+}
+
+func selectPort(match PortMatch, list []*PortDetails) (name string, err error) {
+	names := []string{}
+	for _, details := range list {
+		if match.matches(details) {
+			names = append(names, details.Name)
+		}
+	}
+	switch len(names) {
+	case 0:
+		err = fmt.Errorf("no port matches %s", match)
+	case 1:
+		name = names[0]
+	default:
+		err = fmt.Errorf("%d ports match %s: %s",
+			len(names), match, strings.Join(names, ", "))
+	}
+	return
+}
+
+func (match PortMatch) matches(details *PortDetails) bool {
+	if match.VID != "" && !strings.EqualFold(match.VID, details.VID) {
+		return false
+	}
+	if match.PID != "" && !strings.EqualFold(match.PID, details.PID) {
+		return false
+	}
+	if match.SerialNumber != "" && match.SerialNumber != details.SerialNumber {
+		return false
+	}
+	if match.Product != "" && !strings.Contains(
+		strings.ToLower(details.Product), strings.ToLower(match.Product)) {
+		return false
+	}
+	if match.Location != "" && match.Location != details.Location &&
+		!strings.HasPrefix(details.Location, match.Location+":") {
+		return false
+	}
+	if match.ByID != "" {
+		if details.ByID == "" {
+			return false
+		}
+		target := filepath.Base(details.ByID)
+		if strings.Contains(match.ByID, "/") {
+			target = details.ByID
+		}
+		ok, err := filepath.Match(match.ByID, target)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (match PortMatch) String() string {
+	fields := []string{}
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, fmt.Sprintf("%s=%q", key, value))
+		}
+	}
+	add("vid", match.VID)
+	add("pid", match.PID)
+	add("serial", match.SerialNumber)
+	add("product", match.Product)
+	add("location", match.Location)
+	add("byid", match.ByID)
+	if len(fields) == 0 {
+		return "{any}"
+	}
+	return "{" + strings.Join(fields, " ") + "}"
+}
@@ -0,0 +1,104 @@
+//go:build linux
+
+package serial
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var sysTtyFolder = "/sys/class/tty"
+var byIDFolder = "/dev/serial/by-id"
+
+func GetDetailedPortsList() (list []*PortDetails, err error) {
+	ports, err := GetPortsList()
+	if err != nil {
+		return
+	}
+	links := readByID()
+	list = make([]*PortDetails, 0, len(ports))
+	for _, name := range ports {
+		list = append(list, portDetails(name, links))
+	}
+	return
+}
+
+// maps resolved device paths to their by-id links
+func readByID() (links map[string]string) {
+	links = map[string]string{}
+	files, err := ioutil.ReadDir(byIDFolder)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		link := filepath.Join(byIDFolder, f.Name())
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+		links[target] = link
+	}
+	return
+}
+
+// ByID if set or the by-id link found now, links show up
+// after udev processed the device so it may still be empty
+func (details *PortDetails) ResolveByID() string {
+	if details.ByID != "" {
+		return details.ByID
+	}
+	target, err := filepath.EvalSymlinks(details.Name)
+	if err != nil {
+		return ""
+	}
+	return readByID()[target]
+}
+
+// never fails, fields that cannot be resolved are left empty
+func portDetails(name string, links map[string]string) (details *PortDetails) {
+	details = &PortDetails{Name: name}
+	if target, err := filepath.EvalSymlinks(name); err == nil {
+		details.ByID = links[target]
+	}
+	device := filepath.Join(sysTtyFolder, filepath.Base(name), "device")
+	path, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return
+	}
+	//usb-serial devices hang one level below the interface
+	//cdc-acm devices are the interface itself
+	iface := ""
+	for dir := path; dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if !fileExists(filepath.Join(dir, "idVendor")) {
+			iface = dir
+			continue
+		}
+		details.IsUSB = true
+		details.VID = strings.ToLower(readSysfs(dir, "idVendor"))
+		details.PID = strings.ToLower(readSysfs(dir, "idProduct"))
+		details.SerialNumber = readSysfs(dir, "serial")
+		details.Manufacturer = readSysfs(dir, "manufacturer")
+		details.Product = readSysfs(dir, "product")
+		details.Location = filepath.Base(dir)
+		if strings.HasPrefix(filepath.Base(iface), details.Location+":") {
+			details.Location = filepath.Base(iface)
+		}
+		break
+	}
+	return
+}
+
+func readSysfs(dir, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
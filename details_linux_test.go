@@ -0,0 +1,65 @@
+//go:build linux
+
+package serial
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPortDetails(t *testing.T) {
+	root := t.TempDir()
+	usb := filepath.Join(root, "devices", "usb1", "1-1")
+	tty := filepath.Join(usb, "1-1:1.0", "ttyUSB0")
+	err := os.MkdirAll(tty, 0755)
+	fatalIfError(t, err)
+	files := map[string]string{
+		"idVendor":     "0403\n",
+		"idProduct":    "6001\n",
+		"serial":       "A50285BI\n",
+		"manufacturer": "FTDI\n",
+		"product":      "FT232R USB UART\n",
+	}
+	for name, data := range files {
+		err = os.WriteFile(filepath.Join(usb, name), []byte(data), 0644)
+		fatalIfError(t, err)
+	}
+	class := filepath.Join(root, "class", "tty", "ttyUSB0")
+	err = os.MkdirAll(class, 0755)
+	fatalIfError(t, err)
+	err = os.Symlink(tty, filepath.Join(class, "device"))
+	fatalIfError(t, err)
+	defer func(folder string) { sysTtyFolder = folder }(sysTtyFolder)
+	sysTtyFolder = filepath.Join(root, "class", "tty")
+	details := portDetails("/dev/ttyUSB0", nil)
+	expected := PortDetails{Name: "/dev/ttyUSB0", IsUSB: true, VID: "0403", PID: "6001",
+		SerialNumber: "A50285BI", Manufacturer: "FTDI", Product: "FT232R USB UART",
+		Location: "1-1:1.0"}
+	if *details != expected {
+		t.Fatalf("unexpected details %+v", details)
+	}
+	details = portDetails("/dev/ttyS9", nil)
+	if details.IsUSB || details.Name != "/dev/ttyS9" {
+		t.Fatalf("unexpected details %+v", details)
+	}
+}
+
+func TestResolveByID(t *testing.T) {
+	root := t.TempDir()
+	dev := filepath.Join(root, "ttyUSB3")
+	fatalIfError(t, os.WriteFile(dev, nil, 0600))
+	defer func(folder string) { byIDFolder = folder }(byIDFolder)
+	byIDFolder = filepath.Join(root, "by-id")
+	details := &PortDetails{Name: dev}
+	if details.ResolveByID() != "" {
+		t.Fatal("unexpected link")
+	}
+	//links appear once udev settled
+	fatalIfError(t, os.MkdirAll(byIDFolder, 0755))
+	link := filepath.Join(byIDFolder, "usb-FTDI_FT232R_A50285BI-if00-port0")
+	fatalIfError(t, os.Symlink(dev, link))
+	if got := details.ResolveByID(); got != link {
+		t.Fatalf("unexpected link %q", got)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package serial
+
+// by-id links are linux only
+func (details *PortDetails) ResolveByID() string {
+	return details.ByID
+}
+
+func GetDetailedPortsList() (list []*PortDetails, err error) {
+	ports, err := GetPortsList()
+	if err != nil {
+		return
+	}
+	list = make([]*PortDetails, 0, len(ports))
+	for _, name := range ports {
+		list = append(list, &PortDetails{Name: name})
+	}
+	return
+}
@@ -0,0 +1,37 @@
+package serial
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectPort(t *testing.T) {
+	list := []*PortDetails{
+		{Name: "/dev/ttyS0"},
+		{Name: "/dev/ttyUSB0", IsUSB: true, VID: "0403", PID: "6001",
+			SerialNumber: "A50285BI", Product: "FT232R USB UART", Location: "1-1.2:1.0",
+			ByID: "/dev/serial/by-id/usb-FTDI_FT232R_USB_UART_A50285BI-if00-port0"},
+		{Name: "/dev/ttyUSB1", IsUSB: true, VID: "0403", PID: "6001",
+			SerialNumber: "A9Z8Y7X6", Product: "FT232R USB UART", Location: "1-1.3:1.0"},
+	}
+	expectSelected(t, list, PortMatch{SerialNumber: "A9Z8Y7X6"}, "/dev/ttyUSB1")
+	expectSelected(t, list, PortMatch{VID: "0403", Location: "1-1.2"}, "/dev/ttyUSB0")
+	expectSelected(t, list, PortMatch{ByID: "usb-FTDI_*_A50285BI-*"}, "/dev/ttyUSB0")
+	expectSelected(t, list, PortMatch{PID: "6001", Location: "1-1.3:1.0"}, "/dev/ttyUSB1")
+	_, err := selectPort(PortMatch{Product: "ft232r"}, list)
+	if err == nil || !strings.Contains(err.Error(), "2 ports match") {
+		t.Fatalf("multiple match not detected %v", err)
+	}
+	_, err = selectPort(PortMatch{VID: "2341"}, list)
+	if err == nil || !strings.Contains(err.Error(), "no port matches") {
+		t.Fatalf("no match not detected %v", err)
+	}
+}
+
+func expectSelected(t *testing.T, list []*PortDetails, match PortMatch, expected string) {
+	name, err := selectPort(match, list)
+	fatalIfError(t, err)
+	if name != expected {
+		t.Fatalf("%s selected %s instead of %s", match, name, expected)
+	}
+}
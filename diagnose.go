@@ -0,0 +1,137 @@
+package serial
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// collected by Diagnose, fields the platform
+// cannot resolve are left as zero values
+type Report struct {
+	Name      string
+	Exists    bool
+	Target    string // symlinks resolved
+	Mode      os.FileMode
+	Owner     string
+	Group     string
+	Groups    []string // groups of the running process
+	IsRoot    bool
+	IsOwner   bool // process runs as the device owner
+	InGroup   bool // process groups include the device group
+	Readable  bool
+	Writable  bool
+	LockFiles []LockFile
+	Holders   []Holder // processes with the device open
+	Driver    string
+	Problems  []string
+	// access fields are only valid when set, they
+	// are not checked on platforms other than unix
+	AccessChecked bool
+}
+
+type LockFile struct {
+	Path  string
+	PID   int
+	Alive bool
+}
+
+type Holder struct {
+	PID     int
+	Command string
+}
+
+// explains why Open may fail for name
+// never fails, problems found are listed in the report
+func Diagnose(name string) (report Report) {
+	report.Name = name
+	target, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		report.problem("cannot resolve %s: %v", name, err)
+		return
+	}
+	report.Target = target
+	info, err := os.Stat(target)
+	if err != nil {
+		report.problem("cannot stat %s: %v", target, err)
+		return
+	}
+	report.Exists = true
+	report.Mode = info.Mode()
+	if report.Mode&os.ModeCharDevice == 0 {
+		report.problem("%s is not a character device", target)
+	}
+	diagnosePlatform(&report, info)
+	if report.AccessChecked && (!report.Readable || !report.Writable) {
+		if report.IsRoot || report.IsOwner || report.InGroup {
+			report.problem("no read/write access to %s", target)
+		} else {
+			report.problem("no read/write access to %s, add the user to group %s",
+				target, report.Group)
+		}
+	}
+	for _, lock := range report.LockFiles {
+		if lock.Alive {
+			report.problem("locked by pid %d through %s", lock.PID, lock.Path)
+		} else {
+			report.problem("stale lock file %s", lock.Path)
+		}
+	}
+	for _, holder := range report.Holders {
+		if holder.PID != os.Getpid() {
+			report.problem("open by pid %d (%s)", holder.PID, holder.Command)
+		}
+	}
+	return
+}
+
+func (report *Report) problem(format string, args ...interface{}) {
+	report.Problems = append(report.Problems, fmt.Sprintf(format, args...))
+}
+
+func (report Report) String() string {
+	sb := &strings.Builder{}
+	line := func(key string, value interface{}) {
+		fmt.Fprintf(sb, "%-10s %v\n", key+":", value)
+	}
+	line("port", report.Name)
+	line("exists", report.Exists)
+	if !report.Exists {
+		writeProblems(sb, report.Problems)
+		return sb.String()
+	}
+	line("target", report.Target)
+	line("mode", report.Mode)
+	line("owner", report.Owner)
+	line("group", report.Group)
+	if report.AccessChecked {
+		line("groups", strings.Join(report.Groups, " "))
+		line("is root", report.IsRoot)
+		line("is owner", report.IsOwner)
+		line("in group", report.InGroup)
+		line("readable", report.Readable)
+		line("writable", report.Writable)
+	} else {
+		line("access", "not checked")
+	}
+	line("driver", report.Driver)
+	for _, lock := range report.LockFiles {
+		line("lock", fmt.Sprintf("%s pid %d alive %v", lock.Path, lock.PID, lock.Alive))
+	}
+	for _, holder := range report.Holders {
+		line("holder", fmt.Sprintf("pid %d %s", holder.PID, holder.Command))
+	}
+	writeProblems(sb, report.Problems)
+	return sb.String()
+}
+
+func writeProblems(sb *strings.Builder, problems []string) {
+	if len(problems) == 0 {
+		sb.WriteString("no problems found\n")
+		return
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(sb, "problem:   %s\n", problem)
+	}
+}
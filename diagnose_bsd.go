@@ -0,0 +1,10 @@
+//go:build darwin || freebsd || openbsd
+
+package serial
+
+import "os"
+
+// drivers, lock files and holders are linux only
+func diagnosePlatform(report *Report, info os.FileInfo) {
+	diagnoseAccess(report, info)
+}
@@ -0,0 +1,77 @@
+//go:build linux
+
+package serial
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var lockFolders = []string{"/var/lock", "/run/lock", "/var/spool/lock"}
+
+const procFolder = "/proc"
+
+func diagnosePlatform(report *Report, info os.FileInfo) {
+	diagnoseAccess(report, info)
+	base := filepath.Base(report.Target)
+	link, err := os.Readlink(filepath.Join(sysTtyFolder, base, "device", "driver"))
+	if err == nil {
+		report.Driver = filepath.Base(link)
+	}
+	for _, folder := range lockFolders {
+		readLockFile(report, filepath.Join(folder, "LCK.."+base))
+		if base != filepath.Base(report.Name) {
+			readLockFile(report, filepath.Join(folder, "LCK.."+filepath.Base(report.Name)))
+		}
+	}
+	report.Holders = findHolders(report.Target)
+}
+
+// uucp style lock files hold the pid as ascii
+func readLockFile(report *Report, path string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	lock := LockFile{Path: path}
+	lock.PID, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+	if lock.PID > 0 {
+		err = unix.Kill(lock.PID, 0)
+		lock.Alive = err == nil || err == unix.EPERM
+	}
+	report.LockFiles = append(report.LockFiles, lock)
+}
+
+// only processes whose fds are readable by the caller are found
+func findHolders(target string) (holders []Holder) {
+	procs, err := ioutil.ReadDir(procFolder)
+	if err != nil {
+		return
+	}
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+		fdFolder := filepath.Join(procFolder, proc.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdFolder)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdFolder, fd.Name()))
+			if err != nil || link != target {
+				continue
+			}
+			comm, _ := ioutil.ReadFile(filepath.Join(procFolder, proc.Name(), "comm"))
+			holders = append(holders, Holder{pid, strings.TrimSpace(string(comm))})
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package serial
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	report := Diagnose(filepath.Join(t.TempDir(), "ttyUSB9"))
+	if report.Exists || len(report.Problems) != 1 {
+		t.Fatalf("missing port not reported\n%s", report)
+	}
+	name := filepath.Join(t.TempDir(), "ttyUSB9")
+	file, err := os.Create(name)
+	fatalIfError(t, err)
+	defer file.Close()
+	report = Diagnose(name)
+	if !report.Exists || !report.AccessChecked || !report.IsOwner || !report.Readable || !report.Writable {
+		t.Fatalf("invalid access\n%s", report)
+	}
+	found := false
+	for _, holder := range report.Holders {
+		found = found || holder.PID == os.Getpid()
+	}
+	if !found {
+		t.Fatalf("holder not found\n%s", report)
+	}
+	//regular file problem only, own pid is not reported
+	if len(report.Problems) != 1 {
+		t.Fatalf("unexpected problems\n%s", report)
+	}
+}
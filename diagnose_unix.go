@@ -0,0 +1,83 @@
+//go:build linux || darwin || freebsd || openbsd
+
+package serial
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func diagnoseAccess(report *Report, info os.FileInfo) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if ok {
+		report.Owner = lookupUser(stat.Uid)
+		report.Group = lookupGroup(stat.Gid)
+		report.IsRoot = os.Geteuid() == 0
+		report.IsOwner = uint32(os.Geteuid()) == stat.Uid
+		gids, _ := os.Getgroups()
+		gids = append(gids, os.Getegid())
+		for _, gid := range gids {
+			name := lookupGroup(uint32(gid))
+			if uint32(gid) == stat.Gid {
+				report.InGroup = true
+			}
+			report.Groups = appendUnique(report.Groups, name)
+		}
+		if !report.InGroup && configuredInGroup(stat.Gid) {
+			report.problem("user belongs to group %s but this session does not, log in again",
+				report.Group)
+		}
+	}
+	report.Readable = unix.Access(report.Target, unix.R_OK) == nil
+	report.Writable = unix.Access(report.Target, unix.W_OK) == nil
+	report.AccessChecked = true
+}
+
+// group database membership, may differ from the process groups
+func configuredInGroup(gid uint32) bool {
+	u, err := user.Current()
+	if err != nil {
+		return false
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return false
+	}
+	for _, id := range gids {
+		if id == strconv.Itoa(int(gid)) {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupUser(uid uint32) string {
+	id := strconv.Itoa(int(uid))
+	u, err := user.LookupId(id)
+	if err != nil {
+		return id
+	}
+	return u.Username
+}
+
+func lookupGroup(gid uint32) string {
+	id := strconv.Itoa(int(gid))
+	g, err := user.LookupGroupId(id)
+	if err != nil {
+		return id
+	}
+	return g.Name
+}
+
+func appendUnique(list []string, item string) []string {
+	for _, current := range list {
+		if current == item {
+			return list
+		}
+	}
+	return append(list, item)
+}
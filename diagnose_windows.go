@@ -0,0 +1,7 @@
+package serial
+
+import "os"
+
+// nothing is checked, access fields are left unknown
+func diagnosePlatform(report *Report, info os.FileInfo) {
+}
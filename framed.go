@@ -0,0 +1,83 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// returned when no frame ends within the allowed size
+var ErrFrameTooLong = errors.New("frame too long")
+
+// reads delimited frames keeping whatever follows a frame
+// for the next call, owns the read side and its timeout
+type FramedReader struct {
+	in  packetSource
+	buf []byte // received but not returned yet
+}
+
+func NewFramedReader(port Port) *FramedReader {
+	return &FramedReader{in: newPacketSource(port)}
+}
+
+// bytes kept from previous reads
+func (fr *FramedReader) Buffered() int {
+	return len(fr.buf)
+}
+
+// returns the bytes up to and including delim, waiting up to
+// timeout overall, negative waits forever, max limits the frame
+// including delim and is ignored when not positive, on timeout
+// or read errors whatever was received is returned along with
+// ErrTimeout or the error, frames exceeding max are returned cut
+// to max bytes with ErrFrameTooLong, the rest is kept
+func (fr *FramedReader) ReadUntil(delim []byte, max int, timeout time.Duration) (frame []byte, err error) {
+	if len(delim) == 0 {
+		err = errors.New("empty delimiter")
+		return
+	}
+	from := 0
+	scan := func(chunk []byte) (n int, done bool, err error) {
+		fr.buf = append(fr.buf, chunk...)
+		if i := bytes.Index(fr.buf[from:], delim); i >= 0 {
+			end := from + i + len(delim)
+			if max <= 0 || end <= max {
+				frame = fr.take(end)
+				return len(chunk), true, nil
+			}
+		}
+		if max > 0 && len(fr.buf) >= max {
+			frame = fr.take(max)
+			return len(chunk), true, ErrFrameTooLong
+		}
+		//a delimiter may straddle two reads
+		if from = len(fr.buf) - len(delim) + 1; from < 0 {
+			from = 0
+		}
+		return len(chunk), false, nil
+	}
+	if _, done, err := scan(nil); done {
+		return frame, err
+	}
+	err = fr.in.next(timeout, scan)
+	if frame == nil && err != nil {
+		frame = fr.take(len(fr.buf))
+	}
+	return
+}
+
+// reads a \n terminated line and strips \n or \r\n from it,
+// see ReadUntil for max, timeouts and partial lines
+func (fr *FramedReader) ReadLine(max int, timeout time.Duration) (line []byte, err error) {
+	line, err = fr.ReadUntil([]byte{'\n'}, max, timeout)
+	if err == nil {
+		line = bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})
+	}
+	return
+}
+
+func (fr *FramedReader) take(n int) (frame []byte) {
+	frame = append([]byte(nil), fr.buf[:n]...)
+	fr.buf = append(fr.buf[:0], fr.buf[n:]...)
+	return
+}
@@ -0,0 +1,72 @@
+//go:build linux
+
+package serial
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestFramedReader(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	fr := NewFramedReader(port)
+	go func() {
+		master.Write([]byte("ST,GS,+0001.25kg\r"))
+		time.Sleep(20 * time.Millisecond)
+		master.Write([]byte("\nsecond\nthi"))
+	}()
+	line, err := fr.ReadLine(64, time.Second)
+	fatalIfError(t, err)
+	if string(line) != "ST,GS,+0001.25kg" {
+		t.Fatalf("unexpected line %q", line)
+	}
+	line, err = fr.ReadLine(64, time.Second)
+	fatalIfError(t, err)
+	if string(line) != "second" || fr.Buffered() != 3 {
+		t.Fatalf("unexpected line %q %d", line, fr.Buffered())
+	}
+	start := time.Now()
+	line, err = fr.ReadLine(64, 50*time.Millisecond)
+	if err != ErrTimeout || string(line) != "thi" || fr.Buffered() != 0 {
+		t.Fatalf("partial line not returned %q %v", line, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Fatalf("unexpected timeout %v", elapsed)
+	}
+	_, err = master.Write([]byte("0123456789;rest;"))
+	fatalIfError(t, err)
+	frame, err := fr.ReadUntil([]byte(";"), 8, time.Second)
+	if err != ErrFrameTooLong || string(frame) != "01234567" {
+		t.Fatalf("unexpected frame %q %v", frame, err)
+	}
+	frame, err = fr.ReadUntil([]byte(";"), 8, time.Second)
+	fatalIfError(t, err)
+	if string(frame) != "89;" {
+		t.Fatalf("unexpected frame %q", frame)
+	}
+	frame, err = fr.ReadUntil([]byte(";"), 8, time.Second)
+	fatalIfError(t, err)
+	if string(frame) != "rest;" {
+		t.Fatalf("unexpected frame %q", frame)
+	}
+	//delimiter split across reads
+	go func() {
+		master.Write([]byte("OK\r"))
+		time.Sleep(20 * time.Millisecond)
+		master.Write([]byte("\nleft"))
+	}()
+	frame, err = fr.ReadUntil([]byte("\r\n"), 0, time.Second)
+	fatalIfError(t, err)
+	if string(frame) != "OK\r\n" {
+		t.Fatalf("unexpected frame %q", frame)
+	}
+	time.Sleep(20 * time.Millisecond)
+	fatalIfError(t, port.Close())
+	frame, err = fr.ReadUntil([]byte("\r\n"), 0, time.Second)
+	if err != io.EOF {
+		t.Fatalf("close not detected %q %v", frame, err)
+	}
+}
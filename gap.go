@@ -0,0 +1,89 @@
+package serial
+
+import (
+	"time"
+)
+
+// implemented by Device
+type ModeReporter interface {
+	Mode() (*Mode, error)
+}
+
+// time on the wire of one character including start,
+// parity and stop bits, defaults match those of Open
+func CharTime(mode *Mode) time.Duration {
+	half, baud := charHalfBits(mode)
+	return time.Duration(half * int64(time.Second) / (2 * baud))
+}
+
+// 3.5 character times, modbus rtu fixes it to 1.75ms above
+// 19200 baud, pass that explicitly when it applies
+func FrameGap(mode *Mode) time.Duration {
+	half, baud := charHalfBits(mode)
+	return time.Duration(7 * half * int64(time.Second) / (4 * baud))
+}
+
+//counted in half bits for 1.5 stop bits
+func charHalfBits(mode *Mode) (half int64, baud int64) {
+	bits := mode.DataBits
+	if bits == 0 {
+		bits = 8
+	}
+	half = int64(2 + 2*bits)
+	if mode.Parity != NoParity {
+		half += 2
+	}
+	switch mode.StopBits {
+	case OnePointFiveStopBits:
+		half += 3
+	case TwoStopBits:
+		half += 4
+	default:
+		half += 2
+	}
+	baud = int64(mode.BaudRate)
+	if baud <= 0 {
+		baud = 9600
+	}
+	return
+}
+
+// returns one frame per burst of bytes delimited by line
+// idle time, owns the read side and its timeout while reading
+type GapReader struct {
+	cr  *ChunkReader
+	gap time.Duration
+}
+
+// zero or negative gap takes FrameGap of the port mode which
+// requires a ModeReporter, gaps are rounded up to whole ms
+func NewGapReader(port Port, gap time.Duration) (gr *GapReader, err error) {
+	if gap <= 0 {
+		mr, ok := port.(ModeReporter)
+		if !ok {
+			return nil, ErrNotSupported
+		}
+		var mode *Mode
+		mode, err = mr.Mode()
+		if err != nil {
+			return
+		}
+		gap = FrameGap(mode)
+	}
+	gr = &GapReader{cr: NewChunkReader(port, gap), gap: gap}
+	return
+}
+
+func (gr *GapReader) Gap() time.Duration {
+	return gr.gap
+}
+
+// waits up to timeout for a frame to start, negative waits
+// forever, ErrTimeout if none did, see ChunkReader.ReadChunk
+func (gr *GapReader) ReadFrame(timeout time.Duration) (frame []byte, err error) {
+	chunk, err := gr.cr.ReadChunk(timeout)
+	if chunk != nil {
+		frame = chunk.Data
+	}
+	return
+}
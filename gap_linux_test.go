@@ -0,0 +1,44 @@
+//go:build linux
+
+package serial
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGapReader(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	gr, err := NewGapReader(port, 0)
+	fatalIfError(t, err)
+	if gr.Gap() != FrameGap(mode()) {
+		t.Fatalf("unexpected gap %v", gr.Gap())
+	}
+	//ptys deliver instantly, widen the gap for scheduling noise
+	gr, err = NewGapReader(port, 30*time.Millisecond)
+	fatalIfError(t, err)
+	go func() {
+		master.Write([]byte{0x01, 0x03, 0x00})
+		time.Sleep(2 * time.Millisecond)
+		master.Write([]byte{0x00, 0x00, 0x01})
+		time.Sleep(100 * time.Millisecond)
+		master.Write([]byte{0x01, 0x83, 0x02})
+	}()
+	frame, err := gr.ReadFrame(time.Second)
+	fatalIfError(t, err)
+	if string(frame) != "\x01\x03\x00\x00\x00\x01" {
+		t.Fatalf("unexpected frame %x", frame)
+	}
+	frame, err = gr.ReadFrame(time.Second)
+	fatalIfError(t, err)
+	if string(frame) != "\x01\x83\x02" {
+		t.Fatalf("unexpected frame %x", frame)
+	}
+	_, err = gr.ReadFrame(10 * time.Millisecond)
+	if err != ErrTimeout {
+		t.Fatalf("timeout not detected %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package serial
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCharTime(t *testing.T) {
+	for _, tc := range []struct {
+		mode Mode
+		want time.Duration
+	}{
+		{Mode{BaudRate: 9600, DataBits: 8}, 1041666 * time.Nanosecond},
+		{Mode{BaudRate: 19200, DataBits: 8, Parity: EvenParity}, 572916 * time.Nanosecond},
+		{Mode{BaudRate: 9600, DataBits: 7, Parity: OddParity, StopBits: TwoStopBits}, 1145833 * time.Nanosecond},
+		{Mode{BaudRate: 1200, DataBits: 5, StopBits: OnePointFiveStopBits}, 6250 * time.Microsecond},
+		{Mode{}, 1041666 * time.Nanosecond},
+	} {
+		if got := CharTime(&tc.mode); got != tc.want {
+			t.Fatalf("unexpected char time %v for %+v", got, tc.mode)
+		}
+	}
+	mode := &Mode{BaudRate: 9600, DataBits: 8}
+	if got := FrameGap(mode); got != 3645833*time.Nanosecond {
+		t.Fatalf("unexpected frame gap %v", got)
+	}
+}
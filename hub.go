@@ -0,0 +1,116 @@
+package serial
+
+import (
+	"sync"
+)
+
+// owns the read side of a port and broadcasts every chunk to any
+// number of subscribers, each with its own bounded queue, chunks
+// are shared among subscribers and must not be modified, a Block
+// subscriber that stops consuming stalls the whole hub
+type Hub struct {
+	mu    sync.Mutex
+	subs  []*Subscription
+	ended bool
+	stop  chan struct{}
+	once  sync.Once
+	done  chan struct{}
+	err   error
+}
+
+func NewHub(port Port) *Hub {
+	hub := &Hub{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		err := pump(port, hub.broadcast)
+		hub.finish(err)
+	}()
+	return hub
+}
+
+// attaches a new subscriber, it only sees chunks read after
+// this call, Close on the subscription detaches it
+func (hub *Hub) Subscribe(size int, policy Policy) *Subscription {
+	sub := newSubscription(size, policy)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.ended {
+		sub.finish(hub.err)
+	} else {
+		hub.subs = append(hub.subs, sub)
+	}
+	return sub
+}
+
+// closed once the hub stopped reading
+func (hub *Hub) Done() <-chan struct{} {
+	return hub.done
+}
+
+// same as Subscription.Err for the hub read loop
+func (hub *Hub) Err() error {
+	select {
+	case <-hub.done:
+		return hub.err
+	default:
+		return nil
+	}
+}
+
+// stops reading without waiting and ends every subscription
+// with io.EOF, the port is left open
+func (hub *Hub) Close() (err error) {
+	hub.once.Do(func() { close(hub.stop) })
+	return
+}
+
+//runs on the pump goroutine, the only producer of every queue
+func (hub *Hub) broadcast(chunk []byte) bool {
+	hub.mu.Lock()
+	active := hub.subs[:0]
+	for _, sub := range hub.subs {
+		if sub.stopped() {
+			sub.finish(nil)
+		} else {
+			active = append(active, sub)
+		}
+	}
+	for i := len(active); i < len(hub.subs); i++ {
+		hub.subs[i] = nil
+	}
+	hub.subs = active
+	subs := append([]*Subscription(nil), active...)
+	hub.mu.Unlock()
+	if chunk != nil && len(subs) > 0 {
+		shared := append([]byte(nil), chunk...)
+		for _, sub := range subs {
+			//detached ones are finished on the next call
+			sub.push(shared)
+		}
+	}
+	select {
+	case <-hub.stop:
+		return false
+	default:
+		return true
+	}
+}
+
+func (hub *Hub) finish(err error) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	select {
+	case <-hub.stop:
+		err = nil
+	default:
+	}
+	for _, sub := range hub.subs {
+		sub.finish(err)
+	}
+	hub.subs = nil
+	hub.ended = true
+	hub.err = err
+	close(hub.done)
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+package serial
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestHub(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	hub := NewHub(port)
+	logger := hub.Subscribe(4, Block)
+	decoder := hub.Subscribe(4, DropOldest)
+	send := func(s string) {
+		_, err := master.Write([]byte(s))
+		fatalIfError(t, err)
+	}
+	send("one")
+	expectChunk(t, logger, "one")
+	expectChunk(t, decoder, "one")
+	fatalIfError(t, decoder.Close())
+	expectDone(t, decoder)
+	ui := hub.Subscribe(4, DropNewest)
+	send("two")
+	expectChunk(t, logger, "two")
+	expectChunk(t, ui, "two")
+	if decoder.Err() != nil {
+		t.Fatalf("unexpected error %v", decoder.Err())
+	}
+	fatalIfError(t, port.Close())
+	expectDone(t, logger)
+	expectDone(t, ui)
+	<-hub.Done()
+	if logger.Err() != io.EOF || ui.Err() != io.EOF || hub.Err() != io.EOF {
+		t.Fatalf("close not delivered %v %v %v", logger.Err(), ui.Err(), hub.Err())
+	}
+	late := hub.Subscribe(4, Block)
+	expectDone(t, late)
+	if late.Err() != io.EOF {
+		t.Fatalf("unexpected error %v", late.Err())
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	hub := NewHub(port)
+	sub := hub.Subscribe(1, Block)
+	fatalIfError(t, hub.Close())
+	expectDone(t, sub)
+	if sub.Err() != io.EOF || hub.Err() != nil {
+		t.Fatalf("unexpected errors %v %v", sub.Err(), hub.Err())
+	}
+}
+
+func expectChunk(t *testing.T, sub *Subscription, want string) {
+	select {
+	case chunk := <-sub.Data():
+		if string(chunk) != want {
+			t.Fatalf("unexpected chunk %q", chunk)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("chunk %q not delivered", want)
+	}
+}
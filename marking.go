@@ -0,0 +1,70 @@
+package serial
+
+// per byte line errors reported by ReadWithStatus
+type ByteError uint8
+
+const (
+	ParityError ByteError = 1 << iota
+	FramingError
+	BreakError
+)
+
+const (
+	markNone = iota
+	markFF
+	markFF00
+)
+
+// decodes the PARMRK escaped stream
+// 0xFF 0xFF is a valid 0xFF
+// 0xFF 0x00 0x00 is a break delivered as 0x00
+// 0xFF 0x00 x is x received with a parity or framing error,
+// termios does not tell them apart so it is reported as a
+// parity error when parity is enabled and framing otherwise
+// escapes split across reads are kept for the next decode
+type markDecoder struct {
+	state  int
+	parity bool
+}
+
+// dst and errs must be at least as long as src
+func (dec *markDecoder) decode(src, dst []byte, errs []ByteError) (n int) {
+	for _, b := range src {
+		switch dec.state {
+		case markFF:
+			switch b {
+			case 0xFF:
+				dst[n], errs[n] = 0xFF, 0
+				n++
+				dec.state = markNone
+			case 0x00:
+				dec.state = markFF00
+			default:
+				//never produced by PARMRK, keep the byte
+				dst[n], errs[n] = b, 0
+				n++
+				dec.state = markNone
+			}
+		case markFF00:
+			dst[n] = b
+			switch {
+			case b == 0:
+				errs[n] = BreakError
+			case dec.parity:
+				errs[n] = ParityError
+			default:
+				errs[n] = FramingError
+			}
+			n++
+			dec.state = markNone
+		default:
+			if b == 0xFF {
+				dec.state = markFF
+				continue
+			}
+			dst[n], errs[n] = b, 0
+			n++
+		}
+	}
+	return
+}
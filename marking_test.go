@@ -0,0 +1,34 @@
+package serial
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarkDecoder(t *testing.T) {
+	dec := &markDecoder{parity: true}
+	src := []byte{'a', 0xFF, 0xFF, 'b', 0xFF, 0x00, 'c', 0xFF, 0x00, 0x00, 'd', 0xFF}
+	dst := make([]byte, len(src))
+	errs := make([]ByteError, len(src))
+	n := dec.decode(src, dst, errs)
+	expected := []byte{'a', 0xFF, 'b', 'c', 0x00, 'd'}
+	expectedErrs := []ByteError{0, 0, 0, ParityError, BreakError, 0}
+	if !bytes.Equal(dst[:n], expected) {
+		t.Fatalf("unexpected data %x", dst[:n])
+	}
+	for i, e := range expectedErrs {
+		if errs[i] != e {
+			t.Fatalf("unexpected error at %d %v", i, errs[:n])
+		}
+	}
+	//escape split across reads
+	dec.parity = false
+	n = dec.decode([]byte{0x00}, dst, errs)
+	if n != 0 {
+		t.Fatalf("partial escape decoded %d", n)
+	}
+	n = dec.decode([]byte{'e', 'f'}, dst, errs)
+	if n != 2 || dst[0] != 'e' || errs[0] != FramingError || errs[1] != 0 {
+		t.Fatalf("unexpected split decode %x %v", dst[:n], errs[:n])
+	}
+}
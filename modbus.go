@@ -12,7 +12,7 @@ type portTimedReader struct {
 
 func (to portTimedReader) TimedRead(buf []byte) (c int, err error) {
 	c = 0
-	to.port.SetReadTimeout(modbus.ReadToMs)
+	err = to.port.SetReadTimeout(modbus.ReadToMs)
 	if err != nil {
 		return
 	}
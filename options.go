@@ -0,0 +1,192 @@
+package serial
+
+import (
+	"io"
+	"time"
+)
+
+type Option func(*options)
+
+type options struct {
+	mode           *Mode
+	readTimeout    *time.Duration
+	writeTimeout   time.Duration
+	exclusive      bool
+	dtr            *bool
+	rts            *bool
+	keepSettings   bool
+	raw            RawFlags
+	processing     Processing
+	errorMarking   bool
+	restoreOnClose bool
+	hangupOnClose  *bool
+	carrierDetect  bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	o.mode = &Mode{BaudRate: 9600, DataBits: 8}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+var _ Port = (*Device)(nil)
+var _ io.ReaderFrom = (*Device)(nil)
+var _ io.WriterTo = (*Device)(nil)
+var _ InputResetter = (*Device)(nil)
+var _ ModeReporter = (*Device)(nil)
+
+func Open(portName string, mode *Mode) (port *Device, err error) {
+	port, err = OpenWithOptions(portName, WithMode(mode))
+	return
+}
+
+// defaults to 9600 8N1
+func WithMode(mode *Mode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// negative blocks until some data is available
+// zero returns what is readily available
+// defaults to blocking on unix and 1s on windows
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readTimeout = &timeout
+	}
+}
+
+// zero or negative waits forever, the default
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = timeout
+	}
+}
+
+// denies further opens of the device while open
+// windows ports are always opened exclusively
+func WithExclusive() Option {
+	return func(o *options) {
+		o.exclusive = true
+	}
+}
+
+// asserts or clears DTR right after open
+// left as the driver sets it when not given
+// drivers assert DTR and RTS on first open, clearing them
+// here shortens the pulse but cannot avoid it unless a
+// previous owner left HUPCL cleared and the line low
+func WithDTR(on bool) Option {
+	return func(o *options) {
+		o.dtr = &on
+	}
+}
+
+// asserts or clears RTS right after open
+// left as the driver sets it when not given
+func WithRTS(on bool) Option {
+	return func(o *options) {
+		o.rts = &on
+	}
+}
+
+// HUPCL, drops DTR and RTS on last close when set
+// clear it to keep boards from resetting and modem calls up
+// left as found when not given, not supported on windows
+func WithHangupOnClose(on bool) Option {
+	return func(o *options) {
+		o.hangupOnClose = &on
+	}
+}
+
+// clears CLOCAL so the line honors DCD, reads return EOF
+// on carrier loss, open never waits for carrier because the
+// device is opened non blocking and stays so, reads and writes
+// wait in poll
+// defaults to CLOCAL set for devices that do not drive DCD
+// not supported on windows
+func WithCarrierDetect() Option {
+	return func(o *options) {
+		o.carrierDetect = true
+	}
+}
+
+// groups of termios flags forced by the raw mode
+type RawFlags int
+
+const (
+	RawLocal   RawFlags = 1 << iota // no canonical, echo or signals
+	RawInput                        // no input flow control, mapping or marking
+	RawOutput                       // no output processing
+	RawControl                      // no hardware flow control, receiver on, modem lines ignored
+	RawAll     = RawLocal | RawInput | RawOutput | RawControl
+)
+
+// attaches to the port as configured by others
+// speed, framing, raw flags and VMIN/VTIME are left as found
+// unless explicitly requested with WithRaw, settings are not
+// written at all when nothing was requested
+func WithKeepSettings() Option {
+	return func(o *options) {
+		o.keepSettings = true
+	}
+}
+
+// raw flag groups to apply when keeping settings
+// ports opened without keeping settings are always fully raw
+// ignored on windows where ports are always raw
+func WithRaw(flags RawFlags) Option {
+	return func(o *options) {
+		o.raw |= flags
+	}
+}
+
+// input and output processing, the zero value is raw
+// binary protocols like modbus must keep it raw
+type Processing struct {
+	Canonical   bool // line mode, reads return whole lines or time out waiting for one
+	Echo        bool // echo received characters back
+	InCRtoNL    bool // ICRNL
+	InNLtoCR    bool // INLCR
+	InIgnoreCR  bool // IGNCR
+	OutNLtoCRNL bool // ONLCR
+	OutCRtoNL   bool // OCRNL
+}
+
+// applied on top of the raw mode, not supported on windows
+func WithProcessing(processing Processing) Option {
+	return func(o *options) {
+		o.processing = processing
+	}
+}
+
+// marks bytes received with parity, framing or break errors
+// so that ReadWithStatus can report them, plain reads return
+// the marked stream undecoded, not supported on windows
+func WithErrorMarking() Option {
+	return func(o *options) {
+		o.errorMarking = true
+	}
+}
+
+// restores the settings found at open when closed
+func WithRestoreOnClose() Option {
+	return func(o *options) {
+		o.restoreOnClose = true
+	}
+}
+
+// negative maps to -1, positive rounds up to the next ms
+func durationToMs(d time.Duration) int {
+	if d < 0 {
+		return -1
+	}
+	toms := int(d / time.Millisecond)
+	if d%time.Millisecond != 0 {
+		toms++
+	}
+	return toms
+}
@@ -0,0 +1,67 @@
+package serial
+
+import (
+	"io"
+	"time"
+)
+
+// read side shared by the frame and packet readers, errors
+// read along with data are kept until that data is decoded
+type packetSource struct {
+	r   io.Reader
+	buf []byte
+	pos int
+	end int
+	err error // read along with data still to decode
+}
+
+func newPacketSource(r io.Reader) packetSource {
+	return packetSource{r: r, buf: make([]byte, 256)}
+}
+
+// feeds received chunks to decode until it is done, decode
+// returns how much of the chunk it used and must use it all
+// unless done, waits up to timeout overall, negative waits
+// forever, the timeout only applies when reading from a Port
+func (ps *packetSource) next(timeout time.Duration, decode func(chunk []byte) (n int, done bool, err error)) (err error) {
+	port, timed := ps.r.(Port)
+	var deadline time.Time
+	if timeout >= 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		if ps.pos < ps.end {
+			n, done, err := decode(ps.buf[ps.pos:ps.end])
+			ps.pos += n
+			if done {
+				return err
+			}
+		}
+		if ps.err != nil {
+			err, ps.err = ps.err, nil
+			return
+		}
+		toms := -1
+		if timed {
+			if !deadline.IsZero() {
+				if toms = durationToMs(time.Until(deadline)); toms < 0 {
+					toms = 0
+				}
+			}
+			err = port.SetReadTimeout(toms)
+			if err != nil {
+				return
+			}
+		}
+		var n int
+		n, err = ps.r.Read(ps.buf)
+		ps.pos, ps.end = 0, n
+		if n == 0 && err == nil && timed && toms >= 0 {
+			err = ErrTimeout
+		}
+		if err != nil && n == 0 {
+			return
+		}
+		ps.err, err = err, nil
+	}
+}
@@ -0,0 +1,219 @@
+//go:build linux
+
+package serial
+
+import (
+	"io"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// events dispatched by a Poller
+type Events uint32
+
+const (
+	Readable Events = 1 << iota
+	Writable
+	Hangup // also reported for errors, always enabled
+)
+
+// called from the goroutine running the poller, epoll is level
+// triggered so readable ports must be read and writable interest
+// dropped once there is nothing left to write, handlers may add,
+// modify and remove ports, ports are set to timeout 0 on Add
+type PollHandler func(port *Device, events Events)
+
+type pollEntry struct {
+	port    *Device
+	handler PollHandler
+}
+
+// multiplexes many ports over a single epoll instance so
+// gateways do not need a goroutine parked per port, Run or
+// Wait must be called from a single goroutine at a time
+type Poller struct {
+	mu      sync.Mutex
+	io      sync.RWMutex // shared while waiting, exclusive on close
+	closed  bool
+	epfd    int
+	wake    []int
+	entries map[int32]*pollEntry
+	events  []unix.EpollEvent
+}
+
+func NewPoller() (poller *Poller, err error) {
+	poller = &Poller{
+		wake:    []int{-1, -1},
+		entries: make(map[int32]*pollEntry),
+		events:  make([]unix.EpollEvent, 64),
+	}
+	poller.epfd, err = unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	err = unix.Pipe2(poller.wake, unix.O_CLOEXEC|unix.O_NONBLOCK)
+	if err == nil {
+		err = unix.EpollCtl(poller.epfd, unix.EPOLL_CTL_ADD, poller.wake[0],
+			&unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(poller.wake[0])})
+	}
+	if err != nil {
+		poller.release()
+		return nil, err
+	}
+	return
+}
+
+func toEpoll(events Events) (bits uint32) {
+	bits = unix.EPOLLRDHUP
+	if events&Readable != 0 {
+		bits |= unix.EPOLLIN
+	}
+	if events&Writable != 0 {
+		bits |= unix.EPOLLOUT
+	}
+	return
+}
+
+func fromEpoll(bits uint32) (events Events) {
+	if bits&unix.EPOLLIN != 0 {
+		events |= Readable
+	}
+	if bits&unix.EPOLLOUT != 0 {
+		events |= Writable
+	}
+	if bits&(unix.EPOLLHUP|unix.EPOLLRDHUP|unix.EPOLLERR) != 0 {
+		events |= Hangup
+	}
+	return
+}
+
+// registers port for events, remove it before closing it
+func (poller *Poller) Add(port *Device, events Events, handler PollHandler) (err error) {
+	err = port.SetReadTimeout(0)
+	if err != nil {
+		return
+	}
+	fd := int32(port.Fd())
+	poller.mu.Lock()
+	defer poller.mu.Unlock()
+	if poller.closed {
+		return io.EOF
+	}
+	err = unix.EpollCtl(poller.epfd, unix.EPOLL_CTL_ADD, int(fd),
+		&unix.EpollEvent{Events: toEpoll(events), Fd: fd})
+	if err == nil {
+		poller.entries[fd] = &pollEntry{port: port, handler: handler}
+	}
+	return
+}
+
+// replaces the events port is registered for
+func (poller *Poller) Modify(port *Device, events Events) (err error) {
+	fd := int32(port.Fd())
+	poller.mu.Lock()
+	defer poller.mu.Unlock()
+	if poller.closed {
+		return io.EOF
+	}
+	err = unix.EpollCtl(poller.epfd, unix.EPOLL_CTL_MOD, int(fd),
+		&unix.EpollEvent{Events: toEpoll(events), Fd: fd})
+	return
+}
+
+// handlers called after it returns will not see port
+func (poller *Poller) Remove(port *Device) (err error) {
+	fd := int32(port.Fd())
+	poller.mu.Lock()
+	defer poller.mu.Unlock()
+	if poller.closed {
+		return io.EOF
+	}
+	if poller.entries[fd] == nil {
+		return
+	}
+	delete(poller.entries, fd)
+	err = unix.EpollCtl(poller.epfd, unix.EPOLL_CTL_DEL, int(fd), nil)
+	return
+}
+
+// dispatches events until the poller is closed
+func (poller *Poller) Run() (err error) {
+	for {
+		_, err = poller.Wait(-1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// waits up to toms milliseconds, -1 forever, and dispatches the
+// ready events, n is the number of handlers called, io.EOF once
+// the poller is closed
+func (poller *Poller) Wait(toms int) (n int, err error) {
+	poller.io.RLock()
+	defer poller.io.RUnlock()
+	poller.mu.Lock()
+	closed := poller.closed
+	poller.mu.Unlock()
+	if closed {
+		return 0, io.EOF
+	}
+	var count int
+	for {
+		count, err = unix.EpollWait(poller.epfd, poller.events, toms)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+	for _, event := range poller.events[:count] {
+		if event.Fd == int32(poller.wake[0]) {
+			return n, io.EOF
+		}
+		poller.mu.Lock()
+		entry := poller.entries[event.Fd]
+		poller.mu.Unlock()
+		//removed by an earlier handler
+		if entry == nil {
+			continue
+		}
+		entry.handler(entry.port, fromEpoll(event.Events))
+		n++
+	}
+	return
+}
+
+// wakes Run and waits for it to stop, ports are left open,
+// must not be called from handlers
+func (poller *Poller) Close() (err error) {
+	poller.mu.Lock()
+	if poller.closed {
+		poller.mu.Unlock()
+		return
+	}
+	poller.closed = true
+	poller.mu.Unlock()
+	unix.Write(poller.wake[1], []byte{0})
+	poller.io.Lock()
+	defer poller.io.Unlock()
+	err = poller.release()
+	return
+}
+
+func (poller *Poller) release() (err error) {
+	for _, fd := range poller.wake {
+		if fd >= 0 {
+			unix.Close(fd)
+		}
+	}
+	if poller.epfd >= 0 {
+		err = unix.Close(poller.epfd)
+	}
+	return
+}
@@ -0,0 +1,87 @@
+//go:build linux
+
+package serial
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPoller(t *testing.T) {
+	defer logPanic()
+	poller, err := NewPoller()
+	fatalIfError(t, err)
+	defer poller.Close()
+	type event struct {
+		index  int
+		events Events
+		data   string
+	}
+	got := make(chan event, 16)
+	masters := make([]*os.File, 4)
+	for i := range masters {
+		master, port := openPty(t)
+		defer master.Close()
+		defer port.Close()
+		masters[i] = master
+		index := i
+		err = poller.Add(port, Readable, func(port *Device, events Events) {
+			if events&Hangup != 0 {
+				poller.Remove(port)
+				got <- event{index, events, ""}
+				return
+			}
+			if events&Writable != 0 {
+				port.Write([]byte("pong"))
+				poller.Modify(port, Readable)
+				got <- event{index, events, ""}
+				return
+			}
+			buf := make([]byte, 64)
+			n, _ := port.Read(buf)
+			//ask for writable to answer
+			poller.Modify(port, Writable)
+			got <- event{index, events, string(buf[:n])}
+		})
+		fatalIfError(t, err)
+	}
+	done := make(chan error)
+	go func() { done <- poller.Run() }()
+	expect := func(index int, events Events, data string) {
+		select {
+		case e := <-got:
+			if e.index != index || e.events&events == 0 || e.data != data {
+				t.Fatalf("unexpected event %+v", e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d %d not dispatched", index, events)
+		}
+	}
+	for i := len(masters) - 1; i >= 0; i-- {
+		_, err = masters[i].Write([]byte("ping"))
+		fatalIfError(t, err)
+		expect(i, Readable, "ping")
+		expect(i, Writable, "")
+		buf := make([]byte, 64)
+		n, err := masters[i].Read(buf)
+		fatalIfError(t, err)
+		if string(buf[:n]) != "pong" {
+			t.Fatalf("unexpected reply %q", buf[:n])
+		}
+	}
+	masters[2].Close()
+	expect(2, Hangup, "")
+	fatalIfError(t, poller.Close())
+	select {
+	case err = <-done:
+		fatalIfError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("run not stopped")
+	}
+	_, err = poller.Wait(0)
+	if err != io.EOF {
+		t.Fatalf("close not detected %v", err)
+	}
+}
@@ -1,10 +1,13 @@
 package serial
 
+import "errors"
+
 //go:generate go run golang.org/x/sys/windows/mkwinsyscall -output zsyscall_windows.go syscall_windows.go
 
 //only expected errors are timeout and eof
 //despite different, closed will be reported as EOF
 //SetReadTimeout, Read, and Write must detect EOF
+//Write returns after writing all of p or with an error
 type Port interface {
 	SetReadTimeout(toms int) error
 	Read(p []byte) (n int, err error)
@@ -12,6 +15,12 @@ type Port interface {
 	Close() error
 }
 
+// returned by features the platform or device lacks
+var ErrNotSupported = errors.New("not supported")
+
+// returned by operations that could not complete in time
+var ErrTimeout = errors.New("timeout")
+
 type Mode struct {
 	BaudRate int      // platform dependant
 	DataBits int      // 7 or 8
@@ -2,7 +2,11 @@
 
 package serial
 
-import "golang.org/x/sys/unix"
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
 
 const devFolder = "/dev"
 const regexFilter = "^(cu|tty)\\..*"
@@ -10,3 +14,11 @@ const regexFilter = "^(cu|tty)\\..*"
 const ioctlTcgetattr = unix.TIOCGETA
 const ioctlTcsetattr = unix.TIOCSETA
 const ioctlTcflsh = unix.TIOCFLUSH
+
+// from sys/fcntl.h
+const tcFREAD = 0x1
+
+func flushInput(fd int) error {
+	what := int32(tcFREAD)
+	return ioctlPtr(fd, ioctlTcflsh, unsafe.Pointer(&what))
+}
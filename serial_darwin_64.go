@@ -47,3 +47,7 @@ const tcCRTSCTS uint64 = (tcCCTS_OFLOW | tcCRTS_IFLOW)
 func toTermiosSpeedType(speed uint64) uint64 {
 	return speed
 }
+
+func fromTermiosSpeed(settings *unix.Termios) uint64 {
+	return settings.Ospeed
+}
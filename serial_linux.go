@@ -2,7 +2,11 @@
 
 package serial
 
-import "golang.org/x/sys/unix"
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
 
 const devFolder = "/dev"
 const regexFilter = "(ttyS|ttyUSB|ttyACM|ttyAMA|rfcomm|ttyO)[0-9]{1,3}"
@@ -58,7 +62,70 @@ const tcCRTSCTS uint32 = unix.CRTSCTS
 
 const ioctlTcgetattr = unix.TCGETS
 const ioctlTcsetattr = unix.TCSETS
+const ioctlTcflsh = unix.TCFLSH
+
+// TCFLSH takes its argument by value
+func flushInput(fd int) error {
+	return ioctlInt(fd, ioctlTcflsh, unix.TCIFLUSH)
+}
 
 func toTermiosSpeedType(speed uint32) uint32 {
 	return speed
 }
+
+func fromTermiosSpeed(settings *unix.Termios) uint32 {
+	return settings.Cflag & (unix.CBAUD | unix.CBAUDEX)
+}
+
+// hardware counters since the driver loaded
+type LineCounters struct {
+	CTS        int // modem line transitions
+	DSR        int
+	RNG        int
+	DCD        int
+	RX         int // bytes received
+	TX         int // bytes sent
+	Frame      int // errors
+	Overrun    int
+	Parity     int
+	Break      int
+	BufOverrun int
+}
+
+// struct serial_icounter_struct
+type serialIcounter struct {
+	cts, dsr, rng, dcd int32
+	rx, tx             int32
+	frame, overrun     int32
+	parity, brk        int32
+	bufOverrun         int32
+	reserved           [9]int32
+}
+
+// ErrNotSupported for drivers without counters like ptys
+func (port *Device) LineCounters() (counters LineCounters, err error) {
+	raw := serialIcounter{}
+	err = port.control(func() error {
+		return ioctlPtr(port.handle, unix.TIOCGICOUNT, unsafe.Pointer(&raw))
+	})
+	if err == unix.EINVAL || err == unix.ENOTTY {
+		err = ErrNotSupported
+	}
+	if err != nil {
+		return
+	}
+	counters = LineCounters{
+		CTS:        int(raw.cts),
+		DSR:        int(raw.dsr),
+		RNG:        int(raw.rng),
+		DCD:        int(raw.dcd),
+		RX:         int(raw.rx),
+		TX:         int(raw.tx),
+		Frame:      int(raw.frame),
+		Overrun:    int(raw.overrun),
+		Parity:     int(raw.parity),
+		Break:      int(raw.brk),
+		BufOverrun: int(raw.bufOverrun),
+	}
+	return
+}
@@ -0,0 +1,316 @@
+//go:build linux
+
+package serial
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/samuelventura/go-modbus"
+	"golang.org/x/sys/unix"
+)
+
+func TestSerialLineCounters(t *testing.T) {
+	defer logPanic()
+	port := open(t, PORT1).(*Device)
+	defer port.Close()
+	_, err := port.LineCounters()
+	if err != ErrNotSupported {
+		t.Fatalf("pty counters not rejected %v", err)
+	}
+	defer func(original func(int, uint, unsafe.Pointer) error) {
+		ioctlPtr = original
+	}(ioctlPtr)
+	ioctlPtr = func(fd int, req uint, arg unsafe.Pointer) error {
+		if req != unix.TIOCGICOUNT {
+			return unix.ENOTTY
+		}
+		raw := (*serialIcounter)(arg)
+		raw.rx = 100
+		raw.tx = 50
+		raw.overrun = 2
+		raw.brk = 1
+		return nil
+	}
+	counters, err := port.LineCounters()
+	fatalIfError(t, err)
+	expected := LineCounters{RX: 100, TX: 50, Overrun: 2, Break: 1}
+	if counters != expected {
+		t.Fatalf("unexpected counters %+v", counters)
+	}
+}
+
+func TestSerialResetInputBuffer(t *testing.T) {
+	defer logPanic()
+	port := open(t, PORT1).(*Device)
+	defer port.Close()
+	fatalIfError(t, port.ResetInputBuffer())
+	defer func(original func(int, uint, int) error) {
+		ioctlInt = original
+	}(ioctlInt)
+	var req uint
+	arg := -1
+	ioctlInt = func(fd int, r uint, a int) error {
+		req, arg = r, a
+		return nil
+	}
+	fatalIfError(t, port.ResetInputBuffer())
+	if req != unix.TCFLSH || arg != unix.TCIFLUSH {
+		t.Fatalf("unexpected flush %x %d", req, arg)
+	}
+}
+
+// go test -run none -bench Transaction
+// legacy is the io path before timeouts moved into poll, a
+// blocking fd with VMIN/VTIME written by every SetReadTimeout,
+// cached is the current one, both ends of the link use the
+// variant and every syscall they make is counted, cached trades
+// the termios ioctls for the polls that make close and deadlines
+// work, it is not cheaper in syscalls
+func BenchmarkSerialTransaction(b *testing.B) {
+	b.Run("legacy", func(b *testing.B) {
+		benchmarkTransaction(b, func(port *Device) Port {
+			if err := unix.SetNonblock(port.handle, false); err != nil {
+				b.Fatal(err)
+			}
+			return &legacyPort{port}
+		})
+	})
+	b.Run("cached", func(b *testing.B) {
+		benchmarkTransaction(b, func(port *Device) Port {
+			return port
+		})
+	})
+}
+
+type legacyPort struct {
+	*Device
+}
+
+func (port *legacyPort) SetReadTimeout(toms int) (err error) {
+	vmin := uint8(0)
+	vtime := uint8(0)
+	if toms < 0 {
+		vmin = 1
+	}
+	if toms > 0 {
+		vtime = uint8(toms / 100)
+		if vtime == 0 {
+			vtime = 1
+		}
+	}
+	port.settings.Cc[unix.VMIN] = vmin
+	port.settings.Cc[unix.VTIME] = vtime
+	return tryConvertToEof(setTermSettings(port.Device, port.settings))
+}
+
+func (port *legacyPort) Read(p []byte) (n int, err error) {
+	n, err = sysRead(port.handle, p)
+	err = tryConvertToEof(err)
+	if n < 0 {
+		n = 0
+	}
+	return
+}
+
+func (port *legacyPort) Write(p []byte) (n int, err error) {
+	n, err = sysWrite(port.handle, p)
+	err = tryConvertToEof(err)
+	if n < 0 {
+		n = 0
+	}
+	return
+}
+
+type syscallCounts struct {
+	poll, read, write, ioctl int64
+}
+
+func countSyscalls(counts *syscallCounts) (restore func()) {
+	poll, read, write, ioctl := sysPoll, sysRead, sysWrite, ioctlPtr
+	ioctlByValue := ioctlInt
+	sysPoll = func(fds []unix.PollFd, timeout int) (int, error) {
+		atomic.AddInt64(&counts.poll, 1)
+		return poll(fds, timeout)
+	}
+	sysRead = func(fd int, p []byte) (int, error) {
+		atomic.AddInt64(&counts.read, 1)
+		return read(fd, p)
+	}
+	sysWrite = func(fd int, p []byte) (int, error) {
+		atomic.AddInt64(&counts.write, 1)
+		return write(fd, p)
+	}
+	ioctlPtr = func(fd int, req uint, arg unsafe.Pointer) error {
+		atomic.AddInt64(&counts.ioctl, 1)
+		return ioctl(fd, req, arg)
+	}
+	ioctlInt = func(fd int, req uint, arg int) error {
+		atomic.AddInt64(&counts.ioctl, 1)
+		return ioctlByValue(fd, req, arg)
+	}
+	return func() {
+		sysPoll, sysRead, sysWrite, ioctlPtr = poll, read, write, ioctl
+		ioctlInt = ioctlByValue
+	}
+}
+
+func benchmarkTransaction(b *testing.B, variant func(port *Device) Port) {
+	port1, err := Open(PORT1, mode())
+	if err != nil {
+		b.Fatal(err)
+	}
+	port2, err := Open(PORT2, mode())
+	if err != nil {
+		b.Fatal(err)
+	}
+	counts := &syscallCounts{}
+	defer countSyscalls(counts)()
+	done := make(chan bool)
+	defer func() { <-done }()
+	defer port2.Close()
+	defer port1.Close()
+	end1, end2 := variant(port1), variant(port2)
+	proto := modbus.NewRtuProtocol()
+	trans1 := modbus.NewIoTransport(NewTimedReader(end1), end1)
+	trans2 := modbus.NewIoTransport(NewTimedReader(end2), end2)
+	exec := modbus.NewModelExecutor(modbus.NewMapModel())
+	go func() {
+		defer func() { done <- true }()
+		modbus.RunSlave(proto, trans2, exec)
+	}()
+	master := modbus.NewMaster(proto, trans1, 400)
+	//idle slave timeouts are not part of the transactions
+	for _, c := range []*int64{&counts.poll, &counts.read, &counts.write, &counts.ioctl} {
+		atomic.StoreInt64(c, 0)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = master.ReadWos(1, 0, 4)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	n := float64(b.N)
+	poll := float64(atomic.LoadInt64(&counts.poll))
+	read := float64(atomic.LoadInt64(&counts.read))
+	write := float64(atomic.LoadInt64(&counts.write))
+	ioctl := float64(atomic.LoadInt64(&counts.ioctl))
+	b.ReportMetric(poll/n, "poll/op")
+	b.ReportMetric(read/n, "read/op")
+	b.ReportMetric(write/n, "write/op")
+	b.ReportMetric(ioctl/n, "ioctl/op")
+	b.ReportMetric((poll+read+write+ioctl)/n, "syscalls/op")
+}
+
+// in process pty pair, the master is driven directly
+// and the slave is opened as a port
+func openPty(t testing.TB) (master *os.File, port *Device) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := int(master.Fd())
+	err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err = Open(fmt.Sprintf("/dev/pts/%d", index), mode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestSerialSignalStress(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	done := make(chan bool)
+	defer close(done)
+	//runtime preemption uses SIGURG, flood it
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				syscall.Kill(os.Getpid(), syscall.SIGURG)
+				time.Sleep(20 * time.Microsecond)
+			}
+		}
+	}()
+	payload := make([]byte, 4*1024*1024)
+	for i := range payload {
+		payload[i] = byte(i * 7)
+	}
+	//port to master, pty buffers force partial writes
+	go func() {
+		n, err := port.Write(payload)
+		if err != nil || n != len(payload) {
+			t.Errorf("short write %d %v", n, err)
+		}
+	}()
+	received := make([]byte, len(payload))
+	_, err := io.ReadFull(master, received)
+	fatalIfError(t, err)
+	if !bytes.Equal(received, payload) {
+		t.Fatal("payload corrupted port to master")
+	}
+	//master to port
+	go master.Write(payload)
+	received = make([]byte, 0, len(payload))
+	buf := make([]byte, 4096)
+	for len(received) < len(payload) {
+		n, err := port.Read(buf)
+		fatalIfError(t, err)
+		received = append(received, buf[:n]...)
+	}
+	if !bytes.Equal(received, payload) {
+		t.Fatal("payload corrupted master to port")
+	}
+}
+
+func TestSerialSyscallConn(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	var _ syscall.Conn = port
+	conn, err := port.SyscallConn()
+	fatalIfError(t, err)
+	_, err = master.Write([]byte("raw"))
+	fatalIfError(t, err)
+	buf := make([]byte, 3)
+	count := 0
+	err = conn.Read(func(fd uintptr) bool {
+		n, err := unix.Read(int(fd), buf[count:])
+		if err == unix.EAGAIN {
+			return false
+		}
+		count += n
+		return count == len(buf)
+	})
+	fatalIfError(t, err)
+	if string(buf) != "raw" || port.Fd() == 0 {
+		t.Fatalf("unexpected data %q", buf)
+	}
+	port.Close()
+	err = conn.Control(func(fd uintptr) {})
+	if err != io.EOF {
+		t.Fatalf("closed not detected %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"runtime/debug"
 	"testing"
+	"time"
 
 	"github.com/samuelventura/go-modbus"
 	"github.com/samuelventura/go-modbus/spec"
@@ -41,6 +42,26 @@ func testSerialEof(t *testing.T, name string) {
 	}
 }
 
+func TestSerialOptions(t *testing.T) {
+	defer logPanic()
+	port, err := OpenWithOptions(PORT1,
+		WithMode(mode()),
+		WithReadTimeout(150*time.Millisecond),
+		WithRestoreOnClose())
+	fatalIfError(t, err)
+	defer port.Close()
+	start := time.Now()
+	n, err := port.Read(make([]byte, 1))
+	fatalIfError(t, err)
+	elapsed := time.Since(start)
+	if n != 0 || elapsed < 100*time.Millisecond {
+		t.Fatalf("read timeout not applied %d %v", n, elapsed)
+	}
+	if durationToMs(-time.Second) != -1 || durationToMs(1500*time.Microsecond) != 2 {
+		t.Fatal("invalid duration conversion")
+	}
+}
+
 func TestSerialTransport(t *testing.T) {
 	defer logPanic()
 	log.SetFlags(log.Lmicroseconds)
@@ -7,15 +7,32 @@ import (
 	"io"
 	"io/ioutil"
 	"regexp"
+	"sync"
 	"syscall"
+	"time"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
 
-type portDto struct {
-	settings *unix.Termios
-	name     string
-	handle   int
+// Device is an open serial port as returned by Open
+// one reader, one writer and any number of closers may run
+// concurrently, configuration calls are serialized with each
+// other and may run concurrently with reads and writes
+// close wakes blocked reads and writes which then return EOF
+type Device struct {
+	mu           sync.Mutex   // guards closed, settings and timeouts
+	io           sync.RWMutex // shared by reads and writes, exclusive by close
+	closed       bool
+	settings     *unix.Termios
+	restore      *unix.Termios
+	name         string
+	handle       int
+	wake         []int // pipe made readable by close
+	readTimeout  int
+	writeTimeout int
+	marking      *markDecoder
+	markBuf      []byte
 }
 
 func GetPortsList() (ports []string, err error) {
@@ -50,7 +67,8 @@ func GetPortsList() (ports []string, err error) {
 	return
 }
 
-func Open(portName string, mode *Mode) (port *portDto, err error) {
+func OpenWithOptions(portName string, opts ...Option) (port *Device, err error) {
+	o := newOptions(opts)
 	h, err := unix.Open(portName,
 		unix.O_RDWR|unix.O_NOCTTY|unix.O_NDELAY,
 		0)
@@ -58,9 +76,11 @@ func Open(portName string, mode *Mode) (port *portDto, err error) {
 		return
 	}
 
-	port = &portDto{
-		handle: h,
-		name:   portName,
+	port = &Device{
+		handle:      h,
+		name:        portName,
+		wake:        []int{-1, -1},
+		readTimeout: -1,
 	}
 
 	// prevent handle leaks
@@ -71,10 +91,35 @@ func Open(portName string, mode *Mode) (port *portDto, err error) {
 		}
 	}()
 
-	err = unix.SetNonblock(h, false)
+	//io waits in poll so close can wake it
+	//the handle stays non blocking
+	err = unix.Pipe(port.wake)
 	if err != nil {
 		return
 	}
+	unix.CloseOnExec(port.wake[0])
+	unix.CloseOnExec(port.wake[1])
+
+	//as soon as possible to shorten the pulse raised by the driver
+	if o.dtr != nil {
+		err = port.SetDTR(*o.dtr)
+		if err != nil {
+			return
+		}
+	}
+	if o.rts != nil {
+		err = port.SetRTS(*o.rts)
+		if err != nil {
+			return
+		}
+	}
+
+	if o.exclusive {
+		err = ioctlPtr(h, unix.TIOCEXCL, nil)
+		if err != nil {
+			return
+		}
+	}
 
 	settings, err := getTermSettings(port)
 	if err != nil {
@@ -82,6 +127,65 @@ func Open(portName string, mode *Mode) (port *portDto, err error) {
 	}
 	port.settings = settings
 
+	if o.restoreOnClose {
+		restore := *settings
+		port.restore = &restore
+	}
+
+	original := *settings
+	raw := o.raw
+	if !o.keepSettings {
+		raw = RawAll
+		err = setTermSettingsMode(o.mode, settings)
+		if err != nil {
+			return
+		}
+		// Block reads until at least one char is available (no timeout)
+		settings.Cc[unix.VMIN] = 1
+		settings.Cc[unix.VTIME] = 0
+	}
+
+	setTermSettingsRaw(raw, settings)
+	setTermSettingsProcessing(o.processing, settings)
+	if o.errorMarking {
+		setTermSettingsMarking(settings)
+		port.marking = &markDecoder{}
+	}
+
+	if o.carrierDetect {
+		settings.Cflag &^= unix.CLOCAL
+	}
+	if o.hangupOnClose != nil {
+		if *o.hangupOnClose {
+			settings.Cflag |= unix.HUPCL
+		} else {
+			settings.Cflag &^= unix.HUPCL
+		}
+	}
+
+	//do not touch attached ports if nothing changed
+	if *settings != original {
+		err = setTermSettings(port, settings)
+		if err != nil {
+			return
+		}
+	}
+
+	if o.readTimeout != nil {
+		err = port.SetReadTimeout(durationToMs(*o.readTimeout))
+		if err != nil {
+			return
+		}
+	}
+	port.writeTimeout = durationToMs(o.writeTimeout)
+	if port.writeTimeout == 0 {
+		port.writeTimeout = -1
+	}
+
+	return
+}
+
+func setTermSettingsMode(mode *Mode, settings *unix.Termios) (err error) {
 	err = setTermSettingsBaudrate(mode.BaudRate, settings)
 	if err != nil {
 		return
@@ -95,106 +199,523 @@ func Open(portName string, mode *Mode) (port *portDto, err error) {
 		return
 	}
 	err = setTermSettingsStopBits(mode.StopBits, settings)
-	if err != nil {
-		return
+	return
+}
+
+func setTermSettingsRaw(flags RawFlags, settings *unix.Termios) {
+	if flags&RawControl != 0 {
+		//disable handshake
+		settings.Cflag &^= tcCRTSCTS
+
+		// Set local mode
+		settings.Cflag |= unix.CREAD
+		settings.Cflag |= unix.CLOCAL
 	}
 
 	// Set raw mode
-	//disable handshake
-	settings.Cflag &^= tcCRTSCTS
+	if flags&RawLocal != 0 {
+		settings.Lflag &^= unix.ICANON
+		settings.Lflag &^= unix.ECHO
+		settings.Lflag &^= unix.ECHOE
+		settings.Lflag &^= unix.ECHOK
+		settings.Lflag &^= unix.ECHONL
+		settings.Lflag &^= unix.ECHOCTL
+		settings.Lflag &^= unix.ECHOPRT
+		settings.Lflag &^= unix.ECHOKE
+		settings.Lflag &^= unix.ISIG
+		settings.Lflag &^= unix.IEXTEN
+	}
+
+	if flags&RawInput != 0 {
+		settings.Iflag &^= unix.IXON
+		settings.Iflag &^= unix.IXOFF
+		settings.Iflag &^= unix.IXANY
+		settings.Iflag &^= unix.INPCK
+		settings.Iflag &^= unix.IGNPAR
+		settings.Iflag &^= unix.PARMRK
+		settings.Iflag &^= unix.ISTRIP
+		settings.Iflag &^= unix.IGNBRK
+		settings.Iflag &^= unix.BRKINT
+		settings.Iflag &^= unix.INLCR
+		settings.Iflag &^= unix.IGNCR
+		settings.Iflag &^= unix.ICRNL
+		settings.Iflag &^= tcIUCLC
+	}
 
-	// Set local mode
-	settings.Cflag |= unix.CREAD
-	settings.Cflag |= unix.CLOCAL
+	if flags&RawOutput != 0 {
+		settings.Oflag &^= unix.OPOST
+	}
+}
 
-	// Set raw mode
-	settings.Lflag &^= unix.ICANON
-	settings.Lflag &^= unix.ECHO
-	settings.Lflag &^= unix.ECHOE
-	settings.Lflag &^= unix.ECHOK
-	settings.Lflag &^= unix.ECHONL
-	settings.Lflag &^= unix.ECHOCTL
-	settings.Lflag &^= unix.ECHOPRT
-	settings.Lflag &^= unix.ECHOKE
-	settings.Lflag &^= unix.ISIG
-	settings.Lflag &^= unix.IEXTEN
-
-	settings.Iflag &^= unix.IXON
-	settings.Iflag &^= unix.IXOFF
-	settings.Iflag &^= unix.IXANY
-	settings.Iflag &^= unix.INPCK
+func setTermSettingsProcessing(processing Processing, settings *unix.Termios) {
+	if processing.Canonical {
+		settings.Lflag |= unix.ICANON
+	}
+	if processing.Echo {
+		settings.Lflag |= unix.ECHO
+	}
+	if processing.InCRtoNL {
+		settings.Iflag |= unix.ICRNL
+	}
+	if processing.InNLtoCR {
+		settings.Iflag |= unix.INLCR
+	}
+	if processing.InIgnoreCR {
+		settings.Iflag |= unix.IGNCR
+	}
+	//output flags are only honored with OPOST
+	if processing.OutNLtoCRNL || processing.OutCRtoNL {
+		settings.Oflag |= unix.OPOST
+		settings.Oflag &^= unix.ONLCR | unix.OCRNL
+		if processing.OutNLtoCRNL {
+			settings.Oflag |= unix.ONLCR
+		}
+		if processing.OutCRtoNL {
+			settings.Oflag |= unix.OCRNL
+		}
+	}
+}
+
+func setTermSettingsMarking(settings *unix.Termios) {
+	settings.Iflag |= unix.PARMRK
 	settings.Iflag &^= unix.IGNPAR
-	settings.Iflag &^= unix.PARMRK
 	settings.Iflag &^= unix.ISTRIP
 	settings.Iflag &^= unix.IGNBRK
 	settings.Iflag &^= unix.BRKINT
-	settings.Iflag &^= unix.INLCR
-	settings.Iflag &^= unix.IGNCR
-	settings.Iflag &^= unix.ICRNL
-	settings.Iflag &^= tcIUCLC
+	//also gates framing error marking, needed without parity
+	settings.Iflag |= unix.INPCK
+}
 
-	settings.Oflag &^= unix.OPOST
+// escape hatch for termios flags and characters not wrapped
+// fn modifies a copy of the cached settings, the copy is applied
+// in a single ioctl and the cache updated from what the driver
+// accepted so later calls like SetReadTimeout preserve it
+// fn runs unlocked and may call other methods, when the settings
+// change while it runs fn is called again on the new settings
+// so no concurrent change is lost, fn may run more than once
+func (port *Device) ModifyTermios(fn func(settings *unix.Termios)) (err error) {
+	for {
+		before := port.Termios()
+		settings := before
+		fn(&settings)
+		changed := false
+		err = port.control(func() (err error) {
+			if *port.settings != before {
+				changed = true
+				return
+			}
+			err = setTermSettings(port, &settings)
+			if err != nil {
+				return
+			}
+			applied, err := getTermSettings(port)
+			if err != nil {
+				return
+			}
+			*port.settings = *applied
+			return
+		})
+		if err != nil || !changed {
+			return
+		}
+	}
+}
 
-	// Block reads until at least one char is available (no timeout)
-	settings.Cc[unix.VMIN] = 1
-	settings.Cc[unix.VTIME] = 0
+// copy of the cached settings
+func (port *Device) Termios() unix.Termios {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	return *port.settings
+}
 
-	err = setTermSettings(port, settings)
-	if err != nil {
+// decodes the cached settings, fails for values Mode cannot represent
+func (port *Device) Mode() (mode *Mode, err error) {
+	settings := port.Termios()
+	mode = &Mode{}
+	speed := fromTermiosSpeed(&settings)
+	for rate, code := range baudrateMap {
+		if rate != 0 && code == speed {
+			mode.BaudRate = rate
+		}
+	}
+	if mode.BaudRate == 0 {
+		err = fmt.Errorf("unknown speed %x", speed)
+		return
+	}
+	for bits, code := range databitsMap {
+		if bits != 0 && code == settings.Cflag&unix.CSIZE {
+			mode.DataBits = bits
+		}
+	}
+	switch {
+	case settings.Cflag&unix.PARENB == 0:
+		mode.Parity = NoParity
+	case settings.Cflag&tcCMSPAR != 0:
+		err = fmt.Errorf("unsupported mark/space parity")
 		return
+	case settings.Cflag&unix.PARODD != 0:
+		mode.Parity = OddParity
+	default:
+		mode.Parity = EvenParity
+	}
+	mode.StopBits = OneStopBit
+	if settings.Cflag&unix.CSTOPB != 0 {
+		mode.StopBits = TwoStopBits
 	}
+	return
+}
 
+// < 0 blocking, wait for at least 1 char
+// 0 poll, read what is readily available
+// > 0 fully timed
+// reads wait in poll so only the cached value changes,
+// timed readers may call it before every read for free
+func (port *Device) SetReadTimeout(toms int) (err error) {
+	err = port.control(func() error {
+		port.readTimeout = toms
+		return nil
+	})
 	return
 }
 
-func (port *portDto) SetReadTimeout(toms int) (err error) {
-	// http://unixwiz.net/techtips/termios-vmin-vtime.html
-	// < 0 blocking, wait for at least 1 char
-	// 0 poll, read what is readily available
-	// > 0 fully timed
-	vmin := uint8(0)
-	vtime := uint8(0)
-	if toms < 0 {
-		vmin = 1
+// zero or negative waits forever
+func (port *Device) SetWriteTimeout(toms int) (err error) {
+	if toms == 0 {
+		toms = -1
 	}
-	if toms > 0 {
-		vtime = uint8(toms / 100)
-		if vtime == 0 {
-			//ensure some time when some required
-			vtime = 1
-		}
+	err = port.control(func() error {
+		port.writeTimeout = toms
+		return nil
+	})
+	return
+}
+
+func (port *Device) SetDTR(on bool) (err error) {
+	err = port.setModemBits(unix.TIOCM_DTR, on)
+	return
+}
+
+func (port *Device) SetRTS(on bool) (err error) {
+	err = port.setModemBits(unix.TIOCM_RTS, on)
+	return
+}
+
+func (port *Device) setModemBits(bits int, on bool) (err error) {
+	req := uint(unix.TIOCMBIC)
+	if on {
+		req = unix.TIOCMBIS
+	}
+	value := int32(bits)
+	err = port.control(func() error {
+		return ioctlPtr(port.handle, req, unsafe.Pointer(&value))
+	})
+	return
+}
+
+// discards received data not read yet
+func (port *Device) ResetInputBuffer() (err error) {
+	err = port.control(func() error {
+		return flushInput(port.handle)
+	})
+	return
+}
+
+// the handle stays non blocking and valid until Close
+// use SyscallConn to keep Close from racing with its use
+func (port *Device) Fd() uintptr {
+	return uintptr(port.handle)
+}
+
+// the raw conn waits in poll like Read and Write and
+// returns EOF once the device is closed
+func (port *Device) SyscallConn() (conn syscall.RawConn, err error) {
+	conn = &rawConn{port}
+	return
+}
+
+type rawConn struct {
+	port *Device
+}
+
+func (conn *rawConn) Control(fn func(fd uintptr)) error {
+	port := conn.port
+	if !port.enter() {
+		return io.EOF
 	}
+	defer port.io.RUnlock()
+	fn(uintptr(port.handle))
+	return nil
+}
+
+func (conn *rawConn) Read(fn func(fd uintptr) (done bool)) error {
+	return conn.port.rawWait(unix.POLLIN, fn)
+}
 
-	port.settings.Cc[unix.VMIN] = vmin
-	port.settings.Cc[unix.VTIME] = vtime
+func (conn *rawConn) Write(fn func(fd uintptr) (done bool)) error {
+	return conn.port.rawWait(unix.POLLOUT, fn)
+}
 
-	err = setTermSettings(port, port.settings)
+// calls fn until done waiting for events in between
+func (port *Device) rawWait(events int16, fn func(fd uintptr) bool) (err error) {
+	if !port.enter() {
+		err = io.EOF
+		return
+	}
+	defer port.io.RUnlock()
+	for !fn(uintptr(port.handle)) {
+		_, err = port.wait(events, time.Time{})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// serializes configuration calls, EOF once closed
+func (port *Device) control(fn func() error) (err error) {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	if port.closed {
+		err = io.EOF
+		return
+	}
+	err = fn()
 	err = tryConvertToEof(err)
 	return
 }
 
-func (port *portDto) Close() (err error) {
+// safe to call multiple times and from multiple goroutines
+func (port *Device) Close() (err error) {
+	port.mu.Lock()
+	if port.closed {
+		port.mu.Unlock()
+		return
+	}
+	port.closed = true
+	port.mu.Unlock()
+	//wake blocked io then wait for it to leave
+	//so the handle is not reused under a reader
+	if port.wake[1] >= 0 {
+		unix.Write(port.wake[1], []byte{0})
+	}
+	port.io.Lock()
+	defer port.io.Unlock()
+	if port.restore != nil {
+		//best effort, device may be gone
+		setTermSettings(port, port.restore)
+	}
 	err = unix.Close(port.handle)
+	for _, fd := range port.wake {
+		if fd >= 0 {
+			unix.Close(fd)
+		}
+	}
 	return
 }
 
-func (port *portDto) Read(p []byte) (n int, err error) {
-	n, err = unix.Read(port.handle, p)
-	err = tryConvertToEof(err)
-	// Do not return -1 unix errors
-	if n < 0 {
-		n = 0
+// enters a read or write, false once closed
+func (port *Device) enter() bool {
+	port.io.RLock()
+	port.mu.Lock()
+	closed := port.closed
+	port.mu.Unlock()
+	if closed {
+		port.io.RUnlock()
+	}
+	return !closed
+}
+
+func (port *Device) timeouts() (read, write int) {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	return port.readTimeout, port.writeTimeout
+}
+
+// zero deadline waits forever
+func deadlineFor(toms int) (deadline time.Time) {
+	if toms >= 0 {
+		deadline = time.Now().Add(time.Duration(toms) * time.Millisecond)
 	}
 	return
 }
 
-func (port *portDto) Write(p []byte) (n int, err error) {
-	n, err = unix.Write(port.handle, p)
-	err = tryConvertToEof(err)
-	// Do not return -1 unix errors
-	if n < 0 {
-		n = 0
+// waits for events on the handle until the deadline
+// ready is false on timeout, EOF if close was requested
+func (port *Device) wait(events int16, deadline time.Time) (ready bool, err error) {
+	fds := []unix.PollFd{
+		{Fd: int32(port.handle), Events: events},
+		{Fd: int32(port.wake[0]), Events: unix.POLLIN},
 	}
+	for {
+		toms := -1
+		if !deadline.IsZero() {
+			toms = durationToMs(time.Until(deadline))
+			if toms < 0 {
+				toms = 0
+			}
+		}
+		var n int
+		n, err = sysPoll(fds, toms)
+		if err == unix.EINTR {
+			continue
+		}
+		err = tryConvertToEof(err)
+		if err != nil || n == 0 {
+			return
+		}
+		if fds[1].Revents != 0 || fds[0].Revents&unix.POLLNVAL != 0 {
+			err = io.EOF
+			return
+		}
+		//errors and hangups surface on the next io call
+		ready = true
+		return
+	}
+}
+
+func (port *Device) Read(p []byte) (n int, err error) {
+	n, _, err = port.ReadTimestamped(p)
+	return
+}
+
+// at is taken right after the read syscall returns
+// and carries the monotonic clock reading
+func (port *Device) ReadTimestamped(p []byte) (n int, at time.Time, err error) {
+	return port.read(p, true)
+}
+
+// like Read but waits for data however long it takes
+func (port *Device) readWaiting(p []byte) (n int, err error) {
+	n, _, err = port.read(p, false)
+	return
+}
+
+// waits up to the read timeout when timed
+func (port *Device) read(p []byte, timed bool) (n int, at time.Time, err error) {
+	if !port.enter() {
+		err = io.EOF
+		return
+	}
+	defer port.io.RUnlock()
+	var deadline time.Time
+	if timed {
+		toms, _ := port.timeouts()
+		deadline = deadlineFor(toms)
+	}
+	for {
+		var ready bool
+		ready, err = port.wait(unix.POLLIN, deadline)
+		if err != nil || !ready {
+			return
+		}
+		n, err = sysRead(port.handle, p)
+		at = time.Now()
+		//spurious wakeup or signal, wait again
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+		err = tryConvertToEof(err)
+		// Do not return -1 unix errors
+		if n < 0 {
+			n = 0
+		}
+		//readable with nothing to read is a hangup
+		if n == 0 && err == nil && len(p) > 0 {
+			err = io.EOF
+		}
+		return
+	}
+}
+
+// reports line errors per byte for ports opened WithErrorMarking
+// errs must be at least as long as p, bytes are reported without
+// errors when marking is not enabled
+func (port *Device) ReadWithStatus(p []byte, errs []ByteError) (n int, err error) {
+	if len(errs) < len(p) {
+		err = fmt.Errorf("errs shorter than data %d < %d", len(errs), len(p))
+		return
+	}
+	if port.marking == nil {
+		n, err = port.Read(p)
+		for i := 0; i < n; i++ {
+			errs[i] = 0
+		}
+		return
+	}
+	port.marking.parity = port.Termios().Cflag&unix.PARENB != 0
+	if len(port.markBuf) < len(p) {
+		port.markBuf = make([]byte, len(p))
+	}
+	raw := port.markBuf[:len(p)]
+	for {
+		var c int
+		c, err = port.Read(raw)
+		n = port.marking.decode(raw[:c], p, errs)
+		//retry reads holding only part of an escape
+		if n > 0 || c == 0 || err != nil {
+			return
+		}
+	}
+}
+
+// returns after writing all of p or on error, partial
+// writes are retried until the write timeout expires
+// in which case n < len(p) with ErrTimeout
+func (port *Device) Write(p []byte) (n int, err error) {
+	if !port.enter() {
+		err = io.EOF
+		return
+	}
+	defer port.io.RUnlock()
+	_, toms := port.timeouts()
+	deadline := deadlineFor(toms)
+	for n < len(p) {
+		var ready bool
+		ready, err = port.wait(unix.POLLOUT, deadline)
+		if err != nil {
+			return
+		}
+		if !ready {
+			err = ErrTimeout
+			return
+		}
+		var c int
+		c, err = sysWrite(port.handle, p[n:])
+		//output buffer full or signal, wait again
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+		err = tryConvertToEof(err)
+		if err != nil {
+			return
+		}
+		n += c
+	}
+	return
+}
+
+// copies r to the port until EOF, regular files go
+// through sendfile on linux, see Write for timeouts
+func (port *Device) ReadFrom(r io.Reader) (n int64, err error) {
+	n, handled, err := port.sendFrom(r)
+	if handled {
+		return
+	}
+	m, err := copyToPort(port, r)
+	n += m
+	return
+}
+
+// copies port data to w until the port is closed or w fails,
+// data is waited for in poll whatever the read timeout,
+// descriptors are fed with splice on linux
+func (port *Device) WriteTo(w io.Writer) (n int64, err error) {
+	n, handled, err := port.spliceTo(w)
+	if handled {
+		return
+	}
+	m, err := copyFromPort(w, port.readWaiting)
+	n += m
 	return
 }
 
@@ -279,10 +800,40 @@ func setTermSettingsStopBits(bits StopBits, settings *unix.Termios) (err error)
 
 // native syscall wrapper functions
 
-func getTermSettings(port *portDto) (*unix.Termios, error) {
-	return unix.IoctlGetTermios(port.handle, ioctlTcgetattr)
+// syscalls on the io path, vars so benchmarks can count them
+var (
+	sysPoll  = unix.Poll
+	sysRead  = unix.Read
+	sysWrite = unix.Write
+)
+
+// all ioctls go through here or ioctlInt so tests can fake drivers
+var ioctlPtr = func(fd int, req uint, arg unsafe.Pointer) (err error) {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
+	if errno != 0 {
+		err = errno
+	}
+	return
+}
+
+// for ioctls taking their argument by value
+var ioctlInt = func(fd int, req uint, arg int) (err error) {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
+	if errno != 0 {
+		err = errno
+	}
+	return
+}
+
+func getTermSettings(port *Device) (settings *unix.Termios, err error) {
+	settings = &unix.Termios{}
+	err = ioctlPtr(port.handle, ioctlTcgetattr, unsafe.Pointer(settings))
+	if err != nil {
+		settings = nil
+	}
+	return
 }
 
-func setTermSettings(port *portDto, settings *unix.Termios) error {
-	return unix.IoctlSetTermios(port.handle, ioctlTcsetattr, settings)
+func setTermSettings(port *Device, settings *unix.Termios) error {
+	return ioctlPtr(port.handle, ioctlTcsetattr, unsafe.Pointer(settings))
 }
@@ -2,7 +2,293 @@
 
 package serial
 
+import (
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
 const (
 	PORT1 = "/tmp/tty.master" //1
 	PORT2 = "/tmp/tty.slave"  //2
 )
+
+func TestSerialHangupOnClose(t *testing.T) {
+	defer logPanic()
+	port, err := OpenWithOptions(PORT1,
+		WithMode(mode()),
+		WithHangupOnClose(false),
+		WithCarrierDetect())
+	fatalIfError(t, err)
+	defer port.Close()
+	settings, err := getTermSettings(port)
+	fatalIfError(t, err)
+	if settings.Cflag&unix.HUPCL != 0 || settings.Cflag&unix.CLOCAL != 0 {
+		t.Fatalf("invalid cflag %x", settings.Cflag)
+	}
+}
+
+func TestSerialKeepSettings(t *testing.T) {
+	defer logPanic()
+	//ptys force CS8 and no parity
+	mode := &Mode{BaudRate: 19200, DataBits: 8, Parity: NoParity, StopBits: TwoStopBits}
+	port, err := Open(PORT1, mode)
+	fatalIfError(t, err)
+	settings := *port.settings
+	settings.Lflag |= unix.ICANON
+	err = setTermSettings(port, &settings)
+	fatalIfError(t, err)
+	port.Close()
+	port, err = OpenWithOptions(PORT1, WithKeepSettings())
+	fatalIfError(t, err)
+	found, err := port.Mode()
+	fatalIfError(t, err)
+	if *found != *mode || port.settings.Lflag&unix.ICANON == 0 {
+		t.Fatalf("settings not kept %+v %x", found, port.settings.Lflag)
+	}
+	port.Close()
+	port, err = OpenWithOptions(PORT1, WithKeepSettings(), WithRaw(RawLocal))
+	fatalIfError(t, err)
+	defer port.Close()
+	settings2, err := getTermSettings(port)
+	fatalIfError(t, err)
+	if settings2.Lflag&unix.ICANON != 0 || settings2.Cflag&unix.CSTOPB == 0 {
+		t.Fatalf("raw flags not applied %x %x", settings2.Lflag, settings2.Cflag)
+	}
+}
+
+func TestSerialModifyTermios(t *testing.T) {
+	defer logPanic()
+	port := open(t, PORT1).(*Device)
+	defer port.Close()
+	err := port.ModifyTermios(func(settings *unix.Termios) {
+		settings.Iflag |= unix.IGNPAR
+		settings.Cc[unix.VEOL] = '\r'
+	})
+	fatalIfError(t, err)
+	err = port.SetReadTimeout(100)
+	fatalIfError(t, err)
+	settings, err := getTermSettings(port)
+	fatalIfError(t, err)
+	if settings.Iflag&unix.IGNPAR == 0 || settings.Cc[unix.VEOL] != '\r' {
+		t.Fatalf("termios changes lost %x %v", settings.Iflag, settings.Cc)
+	}
+	if port.Termios().Iflag&unix.IGNPAR == 0 {
+		t.Fatalf("cache not updated %x", port.Termios().Iflag)
+	}
+	//callbacks may use locked accessors
+	done := make(chan error, 1)
+	go func() {
+		done <- port.ModifyTermios(func(settings *unix.Termios) {
+			mode, err := port.Mode()
+			if err == nil && mode.StopBits == OneStopBit {
+				settings.Cflag |= unix.CSTOPB
+			}
+		})
+	}()
+	select {
+	case err = <-done:
+		fatalIfError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("callback deadlocked")
+	}
+	if port.Termios().Cflag&unix.CSTOPB == 0 {
+		t.Fatalf("callback change lost %x", port.Termios().Cflag)
+	}
+	//a change made while fn runs is kept and fn retried, the pty
+	//keeps settings across opens so values differ from the current
+	cached := port.Termios()
+	eol2, reprint := cached.Cc[unix.VEOL2]+1, cached.Cc[unix.VREPRINT]+1
+	calls := 0
+	err = port.ModifyTermios(func(settings *unix.Termios) {
+		calls++
+		if calls == 1 {
+			fatalIfError(t, port.ModifyTermios(func(settings *unix.Termios) {
+				settings.Cc[unix.VEOL2] = eol2
+			}))
+		}
+		settings.Cc[unix.VREPRINT] = reprint
+	})
+	fatalIfError(t, err)
+	cached = port.Termios()
+	if calls != 2 || cached.Cc[unix.VREPRINT] != reprint || cached.Cc[unix.VEOL2] != eol2 {
+		t.Fatalf("concurrent change lost %d %v", calls, cached.Cc)
+	}
+}
+
+func TestSerialProcessing(t *testing.T) {
+	defer logPanic()
+	port1, err := OpenWithOptions(PORT1, WithMode(mode()),
+		WithProcessing(Processing{Canonical: true, InCRtoNL: true, OutNLtoCRNL: true}))
+	fatalIfError(t, err)
+	defer port1.Close()
+	port2 := open(t, PORT2)
+	defer port2.Close()
+	_, err = port2.Write([]byte("abc\r"))
+	fatalIfError(t, err)
+	buf := make([]byte, 16)
+	n, err := port1.Read(buf)
+	fatalIfError(t, err)
+	if string(buf[:n]) != "abc\n" {
+		t.Fatalf("unexpected line %q", buf[:n])
+	}
+	_, err = port1.Write([]byte("x\n"))
+	fatalIfError(t, err)
+	err = port2.SetReadTimeout(500)
+	fatalIfError(t, err)
+	data := []byte{}
+	for len(data) < 3 {
+		n, err = port2.Read(buf)
+		fatalIfError(t, err)
+		if n == 0 {
+			break
+		}
+		data = append(data, buf[:n]...)
+	}
+	if string(data) != "x\r\n" {
+		t.Fatalf("unexpected output %q", data)
+	}
+}
+
+func TestSetTermSettingsMarking(t *testing.T) {
+	for _, parity := range []Parity{NoParity, EvenParity} {
+		settings := &unix.Termios{}
+		settings.Iflag = unix.IGNPAR | unix.ISTRIP | unix.IGNBRK | unix.BRKINT
+		fatalIfError(t, setTermSettingsParity(parity, settings))
+		setTermSettingsMarking(settings)
+		want := uint32(unix.PARMRK | unix.INPCK)
+		if uint32(settings.Iflag) != want {
+			t.Fatalf("parity %d unexpected iflag %x", parity, settings.Iflag)
+		}
+	}
+}
+
+func TestSerialErrorMarking(t *testing.T) {
+	defer logPanic()
+	port1, err := OpenWithOptions(PORT1, WithMode(mode()),
+		WithErrorMarking(), WithReadTimeout(time.Second))
+	fatalIfError(t, err)
+	defer port1.Close()
+	port2 := open(t, PORT2)
+	defer port2.Close()
+	_, err = port2.Write([]byte{'a', 0xFF, 'b'})
+	fatalIfError(t, err)
+	data := []byte{}
+	buf := make([]byte, 8)
+	errs := make([]ByteError, len(buf))
+	for len(data) < 3 {
+		n, err := port1.ReadWithStatus(buf, errs)
+		fatalIfError(t, err)
+		if n == 0 {
+			break
+		}
+		for _, e := range errs[:n] {
+			if e != 0 {
+				t.Fatalf("unexpected errors %v", errs[:n])
+			}
+		}
+		data = append(data, buf[:n]...)
+	}
+	if string(data) != "a\xffb" {
+		t.Fatalf("unexpected data %x", data)
+	}
+}
+
+// run with -race, see test.sh
+func TestSerialConcurrent(t *testing.T) {
+	defer logPanic()
+	port1 := open(t, PORT1).(*Device)
+	port2 := open(t, PORT2)
+	errs := make(chan error, 8)
+	loop := func(fn func() error) {
+		go func() {
+			for {
+				err := fn()
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	buf1 := make([]byte, 64)
+	loop(func() error {
+		err := port1.SetReadTimeout(10)
+		if err != nil {
+			return err
+		}
+		_, err = port1.Read(buf1)
+		return err
+	})
+	loop(func() error {
+		_, err := port1.Write([]byte("port1"))
+		return err
+	})
+	loop(func() error {
+		_, err := port1.Mode()
+		if err != nil {
+			return err
+		}
+		err = port1.SetWriteTimeout(-1)
+		if err != nil {
+			return err
+		}
+		return port1.ModifyTermios(func(settings *unix.Termios) {
+			settings.Cc[unix.VEOL] ^= 1
+		})
+	})
+	buf2 := make([]byte, 64)
+	loop(func() error {
+		_, err := port2.Read(buf2)
+		return err
+	})
+	loop(func() error {
+		_, err := port2.Write([]byte("port2"))
+		return err
+	})
+	time.Sleep(200 * time.Millisecond)
+	port2.Close()
+	expectEOF(t, errs, 2)
+	//let port1 drain data in flight
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		go port1.Close()
+	}
+	expectEOF(t, errs, 3)
+}
+
+func expectEOF(t *testing.T, errs chan error, count int) {
+	for i := 0; i < count; i++ {
+		select {
+		case err := <-errs:
+			if err != io.EOF {
+				t.Fatalf("unexpected error %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("close did not unblock io")
+		}
+	}
+}
+
+func TestSerialCloseWakesReader(t *testing.T) {
+	defer logPanic()
+	port := open(t, PORT1)
+	done := make(chan error)
+	go func() {
+		_, err := port.Read(make([]byte, 1))
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	err := port.Close()
+	fatalIfError(t, err)
+	select {
+	case err = <-done:
+		if err != io.EOF {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked read not woken")
+	}
+}
@@ -14,11 +14,16 @@ import (
 	"io"
 	"sync"
 	"syscall"
+	"time"
 )
 
-type portDto struct {
-	mu     sync.Mutex
-	handle syscall.Handle
+// Device is an open serial port as returned by Open
+type Device struct {
+	mu           sync.Mutex
+	handle       syscall.Handle
+	restore      *dcb
+	readTimeout  int
+	writeTimeout int
 }
 
 func GetPortsList() (list []string, err error) {
@@ -55,7 +60,13 @@ func GetPortsList() (list []string, err error) {
 	return
 }
 
-func Open(portName string, mode *Mode) (port *portDto, err error) {
+func OpenWithOptions(portName string, opts ...Option) (port *Device, err error) {
+	o := newOptions(opts)
+	if o.hangupOnClose != nil || o.carrierDetect || o.errorMarking ||
+		o.processing != (Processing{}) {
+		err = ErrNotSupported
+		return
+	}
 	portName = "\\\\.\\" + portName
 	path, err := syscall.UTF16PtrFromString(portName)
 	if err != nil {
@@ -72,7 +83,7 @@ func Open(portName string, mode *Mode) (port *portDto, err error) {
 		return
 	}
 
-	port = &portDto{
+	port = &Device{
 		handle: handle,
 	}
 	defer func() {
@@ -86,24 +97,96 @@ func Open(portName string, mode *Mode) (port *portDto, err error) {
 	if err != nil {
 		return
 	}
-	params.BaudRate = uint32(mode.BaudRate)
-	params.ByteSize = byte(mode.DataBits)
-	params.StopBits = stopBitsMap[mode.StopBits]
-	params.Parity = parityMap[mode.Parity]
-	err = setCommState(handle, &params)
+	if o.restoreOnClose {
+		restore := params
+		port.restore = &restore
+	}
+	if !o.keepSettings {
+		mode := o.mode
+		params.BaudRate = uint32(mode.BaudRate)
+		params.ByteSize = byte(mode.DataBits)
+		params.StopBits = stopBitsMap[mode.StopBits]
+		params.Parity = parityMap[mode.Parity]
+		err = setCommState(handle, &params)
+		if err != nil {
+			return
+		}
+	}
+
+	port.readTimeout = 1000
+	if o.readTimeout != nil {
+		port.readTimeout = durationToMs(*o.readTimeout)
+	}
+	port.writeTimeout = durationToMs(o.writeTimeout)
+	err = port.setTimeouts()
 	if err != nil {
 		return
 	}
 
-	err = port.SetReadTimeout(1000)
+	if o.dtr != nil {
+		err = port.SetDTR(*o.dtr)
+		if err != nil {
+			return
+		}
+	}
+	if o.rts != nil {
+		err = port.SetRTS(*o.rts)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (port *Device) SetReadTimeout(toms int) (err error) {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	if port.handle == 0 {
+		return io.EOF
+	}
+	//timed readers call it before every read
+	if toms == port.readTimeout {
+		return
+	}
+	port.readTimeout = toms
+	err = port.setTimeouts()
+	return
+}
+
+func (port *Device) Mode() (mode *Mode, err error) {
+	params := dcb{}
+	err = getCommState(port.handle, &params)
+	err = tryConvertToEof(err)
 	if err != nil {
 		return
 	}
+	mode = &Mode{}
+	mode.BaudRate = int(params.BaudRate)
+	mode.DataBits = int(params.ByteSize)
+	for parity, code := range parityMap {
+		if code == params.Parity {
+			mode.Parity = parity
+		}
+	}
+	for bits, code := range stopBitsMap {
+		if code == params.StopBits {
+			mode.StopBits = bits
+		}
+	}
+	return
+}
 
+func (port *Device) SetWriteTimeout(toms int) (err error) {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	port.writeTimeout = toms
+	err = port.setTimeouts()
 	return
 }
 
-func (port *portDto) SetReadTimeout(toms int) (err error) {
+func (port *Device) setTimeouts() (err error) {
+	toms := port.readTimeout
 	rinter := uint32(0)
 	rmult := uint32(0)
 	rconst := uint32(0)
@@ -113,11 +196,15 @@ func (port *portDto) SetReadTimeout(toms int) (err error) {
 	if toms > 0 {
 		rconst = uint32(toms)
 	}
+	wconst := uint32(0)
+	if port.writeTimeout > 0 {
+		wconst = uint32(port.writeTimeout)
+	}
 	timeouts := &commTimeouts{
 		ReadIntervalTimeout:           rinter,
 		TimedReadtalTimeoutMultiplier: rmult,
 		TimedReadtalTimeoutConstant:   rconst,
-		WriteTotalTimeoutConstant:     0,
+		WriteTotalTimeoutConstant:     wconst,
 		WriteTotalTimeoutMultiplier:   0,
 	}
 	err = setCommTimeouts(port.handle, timeouts)
@@ -125,24 +212,90 @@ func (port *portDto) SetReadTimeout(toms int) (err error) {
 	return
 }
 
-func (port *portDto) Read(p []byte) (n int, err error) {
+func (port *Device) SetDTR(on bool) (err error) {
+	function := uint32(clrDTR)
+	if on {
+		function = setDTR
+	}
+	err = port.escape(function)
+	return
+}
+
+func (port *Device) SetRTS(on bool) (err error) {
+	function := uint32(clrRTS)
+	if on {
+		function = setRTS
+	}
+	err = port.escape(function)
+	return
+}
+
+// discards received data not read yet
+func (port *Device) ResetInputBuffer() (err error) {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	if port.handle == 0 {
+		return io.EOF
+	}
+	err = purgeComm(port.handle, purgeRxClear)
+	err = tryConvertToEof(err)
+	return
+}
+
+// the handle is valid until Close
+func (port *Device) Fd() uintptr {
+	return uintptr(port.handle)
+}
+
+func (port *Device) escape(function uint32) (err error) {
+	port.mu.Lock()
+	defer port.mu.Unlock()
+	if !escapeCommFunction(port.handle, function) {
+		err = tryConvertToEof(syscall.GetLastError())
+	}
+	return
+}
+
+func (port *Device) Read(p []byte) (n int, err error) {
+	n, _, err = port.ReadTimestamped(p)
+	return
+}
+
+// at is taken right after the read syscall returns
+// and carries the monotonic clock reading
+func (port *Device) ReadTimestamped(p []byte) (n int, at time.Time, err error) {
 	var count uint32
 	err = syscall.ReadFile(port.handle, p, &count, nil)
+	at = time.Now()
 	err = tryConvertToEof(err)
 	n = int(count)
 	return
 }
 
-func (port *portDto) Write(p []byte) (n int, err error) {
+func (port *Device) Write(p []byte) (n int, err error) {
 	var count uint32
 	err = syscall.WriteFile(port.handle, p, &count, nil)
 	err = tryConvertToEof(err)
 	n = int(count)
+	if err == nil && n < len(p) && port.writeTimeout > 0 {
+		err = ErrTimeout
+	}
 	return
 }
 
+// copies r to the port until EOF, see Write for timeouts
+func (port *Device) ReadFrom(r io.Reader) (n int64, err error) {
+	return copyToPort(port, r)
+}
+
+// copies port data to w until the port is closed or w fails,
+// read timeouts do not end the copy
+func (port *Device) WriteTo(w io.Writer) (n int64, err error) {
+	return copyFromPort(w, port.Read)
+}
+
 //mutex to allow safe multi close from go routines
-func (port *portDto) Close() error {
+func (port *Device) Close() error {
 	port.mu.Lock()
 	defer func() {
 		port.handle = 0
@@ -151,6 +304,10 @@ func (port *portDto) Close() error {
 	if port.handle == 0 {
 		return nil
 	}
+	if port.restore != nil {
+		//best effort, device may be gone
+		setCommState(port.handle, port.restore)
+	}
 	return syscall.CloseHandle(port.handle)
 }
 
@@ -209,6 +366,17 @@ type commTimeouts struct {
 	WriteTotalTimeoutConstant     uint32
 }
 
+// EscapeCommFunction functions
+const (
+	setRTS = 3
+	clrRTS = 4
+	setDTR = 5
+	clrDTR = 6
+)
+
+// PurgeComm flags
+const purgeRxClear = 0x0008
+
 const (
 	noParity   = 0
 	oddParity  = 1
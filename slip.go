@@ -0,0 +1,135 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// RFC 1055 special characters
+const (
+	slipEnd    = 0xC0
+	slipEsc    = 0xDB
+	slipEscEnd = 0xDC
+	slipEscEsc = 0xDD
+)
+
+// ESC followed by anything but ESC_END or ESC_ESC
+var ErrSlipEscape = errors.New("invalid slip escape")
+
+// writes whole SLIP packets, each in a single Write
+type SlipEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewSlipEncoder(w io.Writer) *SlipEncoder {
+	return &SlipEncoder{w: w}
+}
+
+// packets are END delimited on both sides so line noise
+// before them is flushed as an empty packet by the peer
+func (enc *SlipEncoder) WritePacket(packet []byte) (err error) {
+	buf := append(enc.buf[:0], slipEnd)
+	for _, b := range packet {
+		switch b {
+		case slipEnd:
+			buf = append(buf, slipEsc, slipEscEnd)
+		case slipEsc:
+			buf = append(buf, slipEsc, slipEscEsc)
+		default:
+			buf = append(buf, b)
+		}
+	}
+	buf = append(buf, slipEnd)
+	enc.buf = buf
+	_, err = enc.w.Write(buf)
+	return
+}
+
+// reads whole SLIP packets, bytes before the first END and
+// the rest of packets that failed to decode are dropped up
+// to the next END, empty packets are skipped
+type SlipDecoder struct {
+	in      packetSource
+	max     int
+	packet  []byte
+	escaped bool
+	synced  bool
+}
+
+// max limits decoded packets and is ignored when not positive
+func NewSlipDecoder(r io.Reader, max int) *SlipDecoder {
+	return &SlipDecoder{in: newPacketSource(r), max: max}
+}
+
+// drops the packet being decoded and waits for the next END
+func (dec *SlipDecoder) Reset() {
+	dec.packet = dec.packet[:0]
+	dec.escaped = false
+	dec.synced = false
+}
+
+// waits up to timeout overall for a packet, negative waits
+// forever, the timeout only applies when reading from a Port,
+// on ErrTimeout the partial packet is kept for the next call,
+// ErrSlipEscape and ErrFrameTooLong drop the packet, read
+// errors are returned as they come
+func (dec *SlipDecoder) ReadPacket(timeout time.Duration) (packet []byte, err error) {
+	err = dec.in.next(timeout, func(chunk []byte) (n int, done bool, err error) {
+		for n < len(chunk) {
+			b := chunk[n]
+			n++
+			packet, err = dec.decode(b)
+			if packet != nil || err != nil {
+				return n, true, err
+			}
+		}
+		return
+	})
+	return
+}
+
+// returns the packet once END completes it
+func (dec *SlipDecoder) decode(b byte) (packet []byte, err error) {
+	//END is not a valid escape, the packet is dropped but the
+	//END still starts the next one
+	if dec.escaped && b == slipEnd {
+		dec.packet = dec.packet[:0]
+		dec.escaped = false
+		return nil, ErrSlipEscape
+	}
+	if b == slipEnd {
+		complete := dec.synced && len(dec.packet) > 0
+		if complete {
+			packet = append([]byte(nil), dec.packet...)
+		}
+		dec.packet = dec.packet[:0]
+		dec.synced = true
+		return
+	}
+	if !dec.synced {
+		return
+	}
+	if dec.escaped {
+		dec.escaped = false
+		switch b {
+		case slipEscEnd:
+			b = slipEnd
+		case slipEscEsc:
+			b = slipEsc
+		default:
+			dec.Reset()
+			return nil, ErrSlipEscape
+		}
+	} else if b == slipEsc {
+		dec.escaped = true
+		return
+	}
+	if dec.max > 0 && len(dec.packet) >= dec.max {
+		dec.Reset()
+		return nil, ErrFrameTooLong
+	}
+	dec.packet = append(dec.packet, b)
+	return
+}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package serial
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlipPort(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	dec := NewSlipDecoder(port, 64)
+	_, err := master.Write([]byte{slipEnd, 'a', slipEsc})
+	fatalIfError(t, err)
+	start := time.Now()
+	_, err = dec.ReadPacket(30 * time.Millisecond)
+	if err != ErrTimeout || time.Since(start) < 30*time.Millisecond {
+		t.Fatalf("timeout not detected %v", err)
+	}
+	//partial packet survives the timeout
+	_, err = master.Write([]byte{slipEscEnd, 'b', slipEnd})
+	fatalIfError(t, err)
+	packet, err := dec.ReadPacket(time.Second)
+	fatalIfError(t, err)
+	if string(packet) != "a\xc0b" {
+		t.Fatalf("unexpected packet % x", packet)
+	}
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := master.Read(buf)
+		master.Write(buf[:n])
+	}()
+	fatalIfError(t, NewSlipEncoder(port).WritePacket([]byte{0x08, slipEnd}))
+	packet, err = dec.ReadPacket(time.Second)
+	fatalIfError(t, err)
+	if string(packet) != "\x08\xc0" {
+		t.Fatalf("unexpected echo % x", packet)
+	}
+}
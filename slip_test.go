@@ -0,0 +1,78 @@
+package serial
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSlipRoundTrip(t *testing.T) {
+	var wire bytes.Buffer
+	enc := NewSlipEncoder(&wire)
+	packets := [][]byte{
+		{0x01, 0x02},
+		{slipEnd, slipEsc, 0x00, slipEscEnd, slipEscEsc},
+		bytes.Repeat([]byte{slipEnd}, 10),
+	}
+	for _, p := range packets {
+		fatalIfError(t, enc.WritePacket(p))
+	}
+	if !bytes.HasPrefix(wire.Bytes(), []byte{slipEnd, 0x01, 0x02, slipEnd, slipEnd, slipEsc, slipEscEnd, slipEsc, slipEscEsc}) {
+		t.Fatalf("unexpected encoding % x", wire.Bytes())
+	}
+	dec := NewSlipDecoder(&wire, 16)
+	for _, want := range packets {
+		got, err := dec.ReadPacket(-1)
+		fatalIfError(t, err)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("unexpected packet % x", got)
+		}
+	}
+	if _, err := dec.ReadPacket(-1); err != io.EOF {
+		t.Fatalf("eof not detected %v", err)
+	}
+}
+
+func TestSlipEscapeEnd(t *testing.T) {
+	wire := []byte{slipEnd, 'a', slipEsc, slipEnd, 'b', 'c', slipEnd}
+	dec := NewSlipDecoder(bytes.NewReader(wire), 0)
+	for _, tc := range []struct {
+		packet string
+		err    error
+	}{
+		{"", ErrSlipEscape},
+		{"bc", nil},
+		{"", io.EOF},
+	} {
+		got, err := dec.ReadPacket(-1)
+		if string(got) != tc.packet || err != tc.err {
+			t.Fatalf("unexpected %q %v expected %q %v", got, err, tc.packet, tc.err)
+		}
+	}
+}
+
+func TestSlipResync(t *testing.T) {
+	wire := []byte("boot messages\r\n")
+	wire = append(wire, slipEnd, 'o', 'k', '1', slipEnd)
+	//bad escape then an oversized packet, both dropped
+	wire = append(wire, 'x', slipEsc, 'y', 'z', slipEnd)
+	wire = append(wire, bytes.Repeat([]byte{'L'}, 9)...)
+	wire = append(wire, slipEnd, slipEnd, 'o', 'k', '2', slipEnd)
+	wire = append(wire, 'p', 'a', 'r')
+	dec := NewSlipDecoder(bytes.NewReader(wire), 8)
+	for _, tc := range []struct {
+		packet string
+		err    error
+	}{
+		{"ok1", nil},
+		{"", ErrSlipEscape},
+		{"", ErrFrameTooLong},
+		{"ok2", nil},
+		{"", io.EOF},
+	} {
+		got, err := dec.ReadPacket(-1)
+		if string(got) != tc.packet || err != tc.err {
+			t.Fatalf("unexpected %q %v expected %q %v", got, err, tc.packet, tc.err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package serial
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// what a subscription does with a chunk when its queue is full
+type Policy int
+
+const (
+	Block      Policy = iota // wait for the consumer
+	DropOldest               // discard the oldest queued chunk
+	DropNewest               // discard the incoming chunk
+)
+
+// read timeout used by pumps to notice stop requests
+const pumpTimeoutMs = 100
+
+// stream of incoming chunks read by a dedicated goroutine,
+// the goroutine owns the read side of the port until the
+// subscription is closed or the port stops delivering data
+type Subscription struct {
+	data    chan []byte
+	policy  Policy
+	dropped uint64
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	err     error
+}
+
+func newSubscription(size int, policy Policy) *Subscription {
+	if size < 1 {
+		size = 1
+	}
+	return &Subscription{
+		data:   make(chan []byte, size),
+		policy: policy,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// delivers chunks read from port through a queue of size chunks,
+// the channel is closed once delivery ends
+func Subscribe(port Port, size int, policy Policy) *Subscription {
+	sub := newSubscription(size, policy)
+	go func() {
+		err := pump(port, func(chunk []byte) bool {
+			if chunk == nil {
+				return !sub.stopped()
+			}
+			return sub.push(append([]byte(nil), chunk...))
+		})
+		sub.finish(err)
+	}()
+	return sub
+}
+
+// calls fn from a dedicated goroutine for every chunk read,
+// data is only valid during the call
+func OnData(port Port, fn func(data []byte)) *Subscription {
+	sub := &Subscription{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		err := pump(port, func(chunk []byte) bool {
+			if chunk != nil {
+				fn(chunk)
+			}
+			return !sub.stopped()
+		})
+		sub.finish(err)
+	}()
+	return sub
+}
+
+// reads until emit returns false or the port fails, emit
+// gets nil on every read timeout, closed ports end with EOF
+func pump(port Port, emit func(chunk []byte) bool) (err error) {
+	err = port.SetReadTimeout(pumpTimeoutMs)
+	if err != nil {
+		return
+	}
+	buf := make([]byte, 4096)
+	for {
+		var n int
+		n, err = port.Read(buf)
+		if n > 0 {
+			if !emit(buf[:n]) {
+				return nil
+			}
+		} else if err == nil && !emit(nil) {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// nil for OnData subscriptions
+func (sub *Subscription) Data() <-chan []byte {
+	return sub.data
+}
+
+// closed once delivery ended
+func (sub *Subscription) Done() <-chan struct{} {
+	return sub.done
+}
+
+// why delivery ended, valid after Done, nil when closed by
+// the subscriber and io.EOF when the port closed or hung up
+func (sub *Subscription) Err() error {
+	select {
+	case <-sub.done:
+		return sub.err
+	default:
+		return nil
+	}
+}
+
+// chunks discarded by the queue policy
+func (sub *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&sub.dropped)
+}
+
+// stops delivery without waiting, safe to call from OnData
+// callbacks and more than once, the port is left open
+func (sub *Subscription) Close() (err error) {
+	sub.once.Do(func() { close(sub.stop) })
+	return
+}
+
+func (sub *Subscription) stopped() bool {
+	select {
+	case <-sub.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// false once the subscriber closed, single producer only
+func (sub *Subscription) push(chunk []byte) bool {
+	if sub.stopped() {
+		return false
+	}
+	switch sub.policy {
+	case DropNewest:
+		select {
+		case sub.data <- chunk:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case sub.data <- chunk:
+				return true
+			default:
+			}
+			select {
+			case <-sub.data:
+				atomic.AddUint64(&sub.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case sub.data <- chunk:
+		case <-sub.stop:
+			return false
+		}
+	}
+	return true
+}
+
+func (sub *Subscription) finish(err error) {
+	if sub.stopped() {
+		err = nil
+	} else if err == nil {
+		err = io.EOF
+	}
+	sub.err = err
+	if sub.data != nil {
+		close(sub.data)
+	}
+	close(sub.done)
+}
@@ -0,0 +1,93 @@
+//go:build linux
+
+package serial
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	sub := Subscribe(port, 4, Block)
+	_, err := master.Write([]byte("hello"))
+	fatalIfError(t, err)
+	select {
+	case chunk := <-sub.Data():
+		if string(chunk) != "hello" {
+			t.Fatalf("unexpected chunk %q", chunk)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("chunk not delivered")
+	}
+	fatalIfError(t, port.Close())
+	expectDone(t, sub)
+	if _, ok := <-sub.Data(); ok {
+		t.Fatal("data channel not closed")
+	}
+	if sub.Err() != io.EOF {
+		t.Fatalf("close not delivered %v", sub.Err())
+	}
+}
+
+func TestSubscribePolicies(t *testing.T) {
+	defer logPanic()
+	for _, tc := range []struct {
+		policy Policy
+		want   string
+	}{
+		{DropNewest, "a"},
+		{DropOldest, "c"},
+	} {
+		master, port := openPty(t)
+		sub := Subscribe(port, 1, tc.policy)
+		for _, s := range []string{"a", "b", "c"} {
+			_, err := master.Write([]byte(s))
+			fatalIfError(t, err)
+			time.Sleep(20 * time.Millisecond)
+		}
+		chunk := <-sub.Data()
+		if string(chunk) != tc.want || sub.Dropped() != 2 {
+			t.Fatalf("policy %d unexpected %q %d", tc.policy, chunk, sub.Dropped())
+		}
+		fatalIfError(t, sub.Close())
+		expectDone(t, sub)
+		if sub.Err() != nil {
+			t.Fatalf("unexpected error %v", sub.Err())
+		}
+		port.Close()
+		master.Close()
+	}
+}
+
+func TestOnData(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	got := make(chan string, 1)
+	sub := OnData(port, func(data []byte) {
+		got <- string(data)
+	})
+	_, err := master.Write([]byte("event"))
+	fatalIfError(t, err)
+	if s := <-got; s != "event" {
+		t.Fatalf("unexpected delivery %q", s)
+	}
+	fatalIfError(t, sub.Close())
+	expectDone(t, sub)
+	if sub.Err() != nil {
+		t.Fatalf("unexpected error %v", sub.Err())
+	}
+}
+
+func expectDone(t *testing.T, sub *Subscription) {
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		t.Fatal("delivery not stopped")
+	}
+}
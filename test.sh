@@ -20,3 +20,4 @@ MODULE=github.com/samuelventura/go-serial
 
 go clean -testcache
 go test
+go test -race -run Concurrent
@@ -0,0 +1,188 @@
+package serial
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// implemented by Device
+type InputResetter interface {
+	ResetInputBuffer() error
+}
+
+// tells whether resp holds a complete response, an error
+// rejects it and fails the attempt, resp grows with every read
+type Matcher func(resp []byte) (complete bool, err error)
+
+// what happened during a transaction
+type TransactResult struct {
+	Response []byte        // last response, maybe partial on failure
+	Attempts int           // attempts started
+	Errors   []error       // one per failed attempt
+	Start    time.Time     // transaction start
+	Sent     time.Time     // last request written
+	Received time.Time     // last response read
+	Elapsed  time.Duration // whole transaction
+}
+
+// time from the last request to its response
+func (res *TransactResult) Latency() time.Duration {
+	if res.Received.Before(res.Sent) {
+		return 0
+	}
+	return res.Received.Sub(res.Sent)
+}
+
+// longest single read while waiting for a response
+const transactPollMs = 100
+
+type TransactOption func(*Transactor)
+
+// extra attempts after the first, 0 by default
+func WithRetries(retries int) TransactOption {
+	return func(tr *Transactor) { tr.retries = retries }
+}
+
+// pause between attempts, none by default
+func WithRetryDelay(delay time.Duration) TransactOption {
+	return func(tr *Transactor) { tr.delay = delay }
+}
+
+// how long each attempt waits for its response, 1s by default
+func WithResponseTimeout(timeout time.Duration) TransactOption {
+	return func(tr *Transactor) { tr.timeout = timeout }
+}
+
+// discard pending input before every request, on by default
+func WithPurge(purge bool) TransactOption {
+	return func(tr *Transactor) { tr.purge = purge }
+}
+
+// runs request response exchanges over a port,
+// owns the port and its read timeout while transacting
+type Transactor struct {
+	port    Port
+	retries int
+	delay   time.Duration
+	timeout time.Duration
+	purge   bool
+	buf     []byte
+}
+
+func NewTransactor(port Port, opts ...TransactOption) *Transactor {
+	tr := &Transactor{
+		port:    port,
+		timeout: time.Second,
+		purge:   true,
+		buf:     make([]byte, 256),
+	}
+	for _, opt := range opts {
+		opt(tr)
+	}
+	return tr
+}
+
+// sends req until matcher accepts a response or attempts run
+// out, failed attempts are retried after the delay unless the
+// port closed or ctx is done, the error is that of the last
+// attempt, ErrTimeout when no complete response arrived in time
+func (tr *Transactor) Transact(ctx context.Context, req []byte, matcher Matcher) (res *TransactResult, err error) {
+	res = &TransactResult{Start: time.Now()}
+	defer func() { res.Elapsed = time.Since(res.Start) }()
+	for {
+		err = tr.attempt(ctx, req, matcher, res)
+		if err == nil {
+			return
+		}
+		res.Errors = append(res.Errors, err)
+		if err == io.EOF || err == context.DeadlineExceeded || ctx.Err() != nil || res.Attempts > tr.retries {
+			return
+		}
+		if tr.delay > 0 {
+			timer := time.NewTimer(tr.delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return res, ctx.Err()
+			}
+		}
+	}
+}
+
+func (tr *Transactor) attempt(ctx context.Context, req []byte, matcher Matcher, res *TransactResult) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	res.Attempts++
+	if tr.purge {
+		err = tr.purgeInput()
+		if err != nil {
+			return
+		}
+	}
+	res.Response = nil
+	_, err = tr.port.Write(req)
+	res.Sent = time.Now()
+	if err != nil {
+		return
+	}
+	deadline := res.Sent.Add(tr.timeout)
+	expired := ErrTimeout
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		//ctx may not have noticed yet
+		deadline, expired = d, context.DeadlineExceeded
+	}
+	for {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		toms := durationToMs(time.Until(deadline))
+		if toms <= 0 {
+			return expired
+		}
+		//short reads so cancellation is noticed
+		if toms > transactPollMs {
+			toms = transactPollMs
+		}
+		err = tr.port.SetReadTimeout(toms)
+		if err != nil {
+			return
+		}
+		var n int
+		n, err = tr.port.Read(tr.buf)
+		if n > 0 {
+			res.Received = time.Now()
+			res.Response = append(res.Response, tr.buf[:n]...)
+			complete, merr := matcher(res.Response)
+			if merr != nil {
+				return merr
+			}
+			if complete {
+				return nil
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ports without InputResetter are drained with polling reads
+func (tr *Transactor) purgeInput() (err error) {
+	if ir, ok := tr.port.(InputResetter); ok {
+		return ir.ResetInputBuffer()
+	}
+	err = tr.port.SetReadTimeout(0)
+	if err != nil {
+		return
+	}
+	for {
+		var n int
+		n, err = tr.port.Read(tr.buf)
+		if n == 0 || err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+//go:build linux
+
+package serial
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errBadResponse = errors.New("bad response")
+
+func lineMatcher(resp []byte) (bool, error) {
+	if resp[0] != '>' {
+		return false, errBadResponse
+	}
+	return bytes.HasSuffix(resp, []byte("\r\n")), nil
+}
+
+func TestTransact(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	//lost, garbled, then answered in two pieces
+	go func() {
+		buf := make([]byte, 64)
+		for i := 0; ; i++ {
+			_, err := master.Read(buf)
+			if err != nil {
+				return
+			}
+			switch i {
+			case 1:
+				master.Write([]byte("?\r\n"))
+			case 2:
+				master.Write([]byte(">OK "))
+				time.Sleep(10 * time.Millisecond)
+				master.Write([]byte("42\r\n"))
+			}
+		}
+	}()
+	tr := NewTransactor(port,
+		WithRetries(3),
+		WithRetryDelay(10*time.Millisecond),
+		WithResponseTimeout(50*time.Millisecond))
+	res, err := tr.Transact(context.Background(), []byte("MEAS?\r\n"), lineMatcher)
+	fatalIfError(t, err)
+	if string(res.Response) != ">OK 42\r\n" || res.Attempts != 3 {
+		t.Fatalf("unexpected result %q %d", res.Response, res.Attempts)
+	}
+	if len(res.Errors) != 2 || res.Errors[0] != ErrTimeout || res.Errors[1] != errBadResponse {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+	if res.Latency() < 10*time.Millisecond || res.Elapsed < 60*time.Millisecond {
+		t.Fatalf("unexpected timings %v %v", res.Latency(), res.Elapsed)
+	}
+}
+
+func TestTransactPurge(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	_, err := master.Write([]byte(">stale\r\n"))
+	fatalIfError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		buf := make([]byte, 64)
+		master.Read(buf)
+		master.Write([]byte(">fresh\r\n"))
+	}()
+	tr := NewTransactor(port)
+	res, err := tr.Transact(context.Background(), []byte("GET\r\n"), lineMatcher)
+	fatalIfError(t, err)
+	if string(res.Response) != ">fresh\r\n" {
+		t.Fatalf("input not purged %q", res.Response)
+	}
+}
+
+func TestTransactCancel(t *testing.T) {
+	defer logPanic()
+	master, port := openPty(t)
+	defer master.Close()
+	defer port.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 80*time.Millisecond)
+	defer cancel()
+	tr := NewTransactor(port, WithRetries(100), WithResponseTimeout(time.Second))
+	res, err := tr.Transact(ctx, []byte("PING\r\n"), lineMatcher)
+	if err != context.DeadlineExceeded || res.Attempts != 1 {
+		t.Fatalf("cancel not detected %v %d", err, res.Attempts)
+	}
+	if res.Elapsed > 500*time.Millisecond {
+		t.Fatalf("cancel too slow %v", res.Elapsed)
+	}
+}
@@ -0,0 +1,35 @@
+package serial
+
+type PortEventType int
+
+const (
+	PortAdded PortEventType = iota
+	PortRemoved
+	WatchFailed // the source failed with Err, last event sent
+)
+
+// name is reported as in GetPortsList
+// details are as in GetDetailedPortsList, ByID may still be
+// empty when udev was slow to create the link, see ResolveByID
+// removed ports keep the details they had while present
+type PortEvent struct {
+	Type    PortEventType
+	Name    string
+	Details *PortDetails
+	Err     error
+}
+
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	inotify bool
+}
+
+// watches /dev with inotify instead of kernel uevents, needed in
+// containers and other network namespaces where the uevent socket
+// binds fine but never receives anything
+func WithInotify() WatchOption {
+	return func(o *watchOptions) {
+		o.inotify = true
+	}
+}
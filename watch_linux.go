@@ -0,0 +1,275 @@
+//go:build linux
+
+package serial
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// emits ports added or removed after the call until ctx is done
+// kernel uevents are used when available, inotify on /dev otherwise
+// or when WithInotify is given, lost events are recovered by
+// rescanning /dev, the channel is closed when ctx is done or after
+// a WatchFailed event
+func WatchPorts(ctx context.Context, opts ...WatchOption) (events <-chan PortEvent, err error) {
+	o := &watchOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	filter, err := regexp.Compile(regexFilter)
+	if err != nil {
+		return
+	}
+	known := map[string]*PortDetails{}
+	list, err := GetDetailedPortsList()
+	if err != nil {
+		return
+	}
+	for _, details := range list {
+		known[details.Name] = details
+	}
+	if !o.inotify {
+		events, err = watchUevents(ctx, filter, known)
+		if err == nil {
+			return
+		}
+	}
+	events, err = watchDir(ctx, devFolder, filter, known)
+	return
+}
+
+func watchUevents(ctx context.Context, filter *regexp.Regexp, known map[string]*PortDetails) (events <-chan PortEvent, err error) {
+	fd, err := unix.Socket(unix.AF_NETLINK,
+		unix.SOCK_DGRAM|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK,
+		unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return
+	}
+	//group 1 is the kernel broadcast group
+	addr := &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}
+	err = unix.Bind(fd, addr)
+	if err != nil {
+		unix.Close(fd)
+		return
+	}
+	file := os.NewFile(uintptr(fd), "uevent")
+	events = runWatch(ctx, file, func(buf []byte, ch chan<- PortEvent) bool {
+		event, ok := parseUevent(buf, filter)
+		return !ok || sendEvent(ctx, ch, event, known)
+	}, func(ch chan<- PortEvent) bool {
+		return rescan(ctx, ch, devFolder, filter, known)
+	})
+	return
+}
+
+// uevent datagrams are NUL separated: action@devpath then KEY=VALUE
+func parseUevent(buf []byte, filter *regexp.Regexp) (event PortEvent, ok bool) {
+	var action, subsystem, devname string
+	for _, field := range bytes.Split(buf, []byte{0}) {
+		kv := strings.SplitN(string(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "ACTION":
+			action = kv[1]
+		case "SUBSYSTEM":
+			subsystem = kv[1]
+		case "DEVNAME":
+			devname = kv[1]
+		}
+	}
+	if subsystem != "tty" || devname == "" {
+		return
+	}
+	//DEVNAME is relative to /dev and may include subfolders
+	if !filter.MatchString(filepath.Base(devname)) {
+		return
+	}
+	event.Name = devFolder + "/" + devname
+	switch action {
+	case "add":
+		event.Type = PortAdded
+	case "remove":
+		event.Type = PortRemoved
+	default:
+		return
+	}
+	ok = true
+	return
+}
+
+func watchDir(ctx context.Context, dir string, filter *regexp.Regexp, known map[string]*PortDetails) (events <-chan PortEvent, err error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return
+	}
+	mask := uint32(unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO)
+	_, err = unix.InotifyAddWatch(fd, dir, mask)
+	if err != nil {
+		unix.Close(fd)
+		return
+	}
+	file := os.NewFile(uintptr(fd), "inotify")
+	events = runWatch(ctx, file, func(buf []byte, ch chan<- PortEvent) bool {
+		for len(buf) >= unix.SizeofInotifyEvent {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+			end := unix.SizeofInotifyEvent + int(raw.Len)
+			if end > len(buf) {
+				break
+			}
+			name := string(bytes.TrimRight(buf[unix.SizeofInotifyEvent:end], "\x00"))
+			buf = buf[end:]
+			if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+				if !rescan(ctx, ch, dir, filter, known) {
+					return false
+				}
+				continue
+			}
+			if raw.Mask&unix.IN_ISDIR != 0 || !filter.MatchString(name) {
+				continue
+			}
+			event := PortEvent{Name: dir + "/" + name}
+			if raw.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0 {
+				event.Type = PortRemoved
+			}
+			if !sendEvent(ctx, ch, event, known) {
+				return false
+			}
+		}
+		return true
+	}, func(ch chan<- PortEvent) bool {
+		return rescan(ctx, ch, dir, filter, known)
+	})
+	return
+}
+
+// file must be non blocking so that closing it unblocks the reader
+// rescan is called when the source reports lost events
+func runWatch(ctx context.Context, file *os.File, handle func(buf []byte, ch chan<- PortEvent) bool,
+	rescan func(ch chan<- PortEvent) bool) <-chan PortEvent {
+	ch := make(chan PortEvent, 16)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		file.Close()
+	}()
+	go func() {
+		defer close(ch)
+		defer close(done)
+		defer file.Close()
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := file.Read(buf)
+			//busy uevent sockets drop datagrams with ENOBUFS
+			if errors.Is(err, unix.ENOBUFS) {
+				if !rescan(ch) {
+					return
+				}
+				continue
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					select {
+					case ch <- PortEvent{Type: WatchFailed, Err: err}:
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+			if !handle(buf[:n], ch) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// sends the additions and removals found by comparing
+// the ports in dir against known
+func rescan(ctx context.Context, ch chan<- PortEvent, dir string, filter *regexp.Regexp, known map[string]*PortDetails) bool {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return true
+	}
+	present := map[string]bool{}
+	for _, f := range files {
+		if f.IsDir() || !filter.MatchString(f.Name()) {
+			continue
+		}
+		name := dir + "/" + f.Name()
+		present[name] = true
+		if known[name] == nil && !sendEvent(ctx, ch, PortEvent{Type: PortAdded, Name: name}, known) {
+			return false
+		}
+	}
+	for name := range known {
+		if !present[name] && !sendEvent(ctx, ch, PortEvent{Type: PortRemoved, Name: name}, known) {
+			return false
+		}
+	}
+	return true
+}
+
+// how long added USB ports wait for udev to create their by-id link
+var byIDSettle = time.Second
+
+// known is only accessed from the watch goroutine, events that
+// do not change it are dropped as the rescan may have sent them
+func sendEvent(ctx context.Context, ch chan<- PortEvent, event PortEvent, known map[string]*PortDetails) bool {
+	switch event.Type {
+	case PortAdded:
+		if known[event.Name] != nil {
+			return true
+		}
+		details, ok := addedDetails(ctx, event.Name)
+		if !ok {
+			return false
+		}
+		event.Details = details
+		known[event.Name] = details
+	case PortRemoved:
+		event.Details = known[event.Name]
+		if event.Details == nil {
+			return true
+		}
+		delete(known, event.Name)
+	}
+	select {
+	case ch <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// udev creates the by-id links after the kernel event, only
+// USB ports get one, false when ctx is done while waiting
+func addedDetails(ctx context.Context, name string) (details *PortDetails, ok bool) {
+	details = portDetails(name, readByID())
+	deadline := time.Now().Add(byIDSettle)
+	for details.IsUSB && details.ByID == "" && time.Now().Before(deadline) {
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			return
+		}
+		details.ByID = details.ResolveByID()
+	}
+	ok = true
+	return
+}
@@ -0,0 +1,157 @@
+//go:build linux
+
+package serial
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestWatchDir(t *testing.T) {
+	dir := t.TempDir()
+	filter := regexp.MustCompile(regexFilter)
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := watchDir(ctx, dir, filter, map[string]*PortDetails{})
+	fatalIfError(t, err)
+	name := filepath.Join(dir, "ttyUSB7")
+	err = os.WriteFile(filepath.Join(dir, "other"), nil, 0600)
+	fatalIfError(t, err)
+	err = os.WriteFile(name, nil, 0600)
+	fatalIfError(t, err)
+	expectEvent(t, events, PortAdded, name)
+	err = os.Remove(name)
+	fatalIfError(t, err)
+	expectEvent(t, events, PortRemoved, name)
+	cancel()
+	select {
+	case event, ok := <-events:
+		if ok {
+			t.Fatalf("unexpected event %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("events not closed")
+	}
+}
+
+func TestWatchRescan(t *testing.T) {
+	dir := t.TempDir()
+	filter := regexp.MustCompile(regexFilter)
+	stale := filepath.Join(dir, "ttyUSB1")
+	known := map[string]*PortDetails{stale: {Name: stale}}
+	added := filepath.Join(dir, "ttyUSB2")
+	fatalIfError(t, os.WriteFile(added, nil, 0600))
+	fatalIfError(t, os.WriteFile(filepath.Join(dir, "other"), nil, 0600))
+	events := make(chan PortEvent, 4)
+	if !rescan(context.Background(), events, dir, filter, known) {
+		t.Fatal("rescan aborted")
+	}
+	expectEvent(t, events, PortAdded, added)
+	expectEvent(t, events, PortRemoved, stale)
+	if len(known) != 1 || known[added] == nil {
+		t.Fatalf("unexpected known %v", known)
+	}
+}
+
+func TestWatchKnown(t *testing.T) {
+	name := "/dev/ttyUSB4"
+	known := map[string]*PortDetails{name: {Name: name}}
+	events := make(chan PortEvent, 2)
+	ctx := context.Background()
+	//already reported by the initial scan or a rescan
+	sendEvent(ctx, events, PortEvent{Type: PortAdded, Name: name}, known)
+	sendEvent(ctx, events, PortEvent{Type: PortRemoved, Name: "/dev/ttyUSB5"}, known)
+	if len(events) != 0 || len(known) != 1 {
+		t.Fatalf("unexpected events %d %v", len(events), known)
+	}
+}
+
+func TestWatchByID(t *testing.T) {
+	root := t.TempDir()
+	usb := filepath.Join(root, "devices", "usb1", "1-1")
+	tty := filepath.Join(usb, "1-1:1.0", "ttyUSB6")
+	fatalIfError(t, os.MkdirAll(tty, 0755))
+	fatalIfError(t, os.WriteFile(filepath.Join(usb, "idVendor"), []byte("0403\n"), 0644))
+	class := filepath.Join(root, "class", "tty", "ttyUSB6")
+	fatalIfError(t, os.MkdirAll(class, 0755))
+	fatalIfError(t, os.Symlink(tty, filepath.Join(class, "device")))
+	dev := filepath.Join(root, "ttyUSB6")
+	fatalIfError(t, os.WriteFile(dev, nil, 0600))
+	fatalIfError(t, os.MkdirAll(filepath.Join(root, "by-id"), 0755))
+	defer func(tty, byID string) { sysTtyFolder, byIDFolder = tty, byID }(sysTtyFolder, byIDFolder)
+	sysTtyFolder = filepath.Join(root, "class", "tty")
+	byIDFolder = filepath.Join(root, "by-id")
+	link := filepath.Join(byIDFolder, "usb-FTDI_FT232R_A50285BI-if00-port0")
+	//udev creates the link after the kernel event
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		os.Symlink(dev, link)
+	}()
+	events := make(chan PortEvent, 1)
+	known := map[string]*PortDetails{}
+	if !sendEvent(context.Background(), events, PortEvent{Type: PortAdded, Name: dev}, known) {
+		t.Fatal("send aborted")
+	}
+	event := <-events
+	if !event.Details.IsUSB || event.Details.ByID != link {
+		t.Fatalf("unexpected details %+v", event.Details)
+	}
+}
+
+func TestWatchFailed(t *testing.T) {
+	r, w, err := os.Pipe()
+	fatalIfError(t, err)
+	w.Close()
+	events := runWatch(context.Background(), r, func(buf []byte, ch chan<- PortEvent) bool {
+		return true
+	}, func(ch chan<- PortEvent) bool {
+		return true
+	})
+	select {
+	case event := <-events:
+		if event.Type != WatchFailed || event.Err == nil {
+			t.Fatalf("unexpected event %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("failure not reported")
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("events not closed")
+	}
+	if _, err := r.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("file not closed %v", err)
+	}
+}
+
+func TestParseUevent(t *testing.T) {
+	filter := regexp.MustCompile(regexFilter)
+	msg := "add@/devices/pci0000:00/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0\x00" +
+		"ACTION=add\x00DEVPATH=/devices/x\x00SUBSYSTEM=tty\x00DEVNAME=ttyUSB0\x00"
+	event, ok := parseUevent([]byte(msg), filter)
+	if !ok || event.Type != PortAdded || event.Name != "/dev/ttyUSB0" {
+		t.Fatalf("unexpected %v %v", ok, event)
+	}
+	msg = "remove@/devices/x\x00ACTION=remove\x00SUBSYSTEM=usb\x00DEVNAME=bus/usb/001/002\x00"
+	_, ok = parseUevent([]byte(msg), filter)
+	if ok {
+		t.Fatal("non tty event accepted")
+	}
+}
+
+func expectEvent(t *testing.T, events <-chan PortEvent, etype PortEventType, name string) {
+	select {
+	case event := <-events:
+		if event.Type != etype || event.Name != name {
+			t.Fatalf("expected %v %s got %v", etype, name, event)
+		}
+		if event.Details == nil || event.Details.Name != name {
+			t.Fatalf("invalid details %v", event.Details)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting %v %s", etype, name)
+	}
+}
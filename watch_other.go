@@ -0,0 +1,10 @@
+//go:build !linux
+
+package serial
+
+import "context"
+
+func WatchPorts(ctx context.Context, opts ...WatchOption) (events <-chan PortEvent, err error) {
+	err = ErrNotSupported
+	return
+}